@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of APIError. Check them with errors.Is.
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrForbidden      = errors.New("forbidden")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrServerError    = errors.New("server error")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// APIError is an error response of a tracker API
+type APIError struct {
+	Kind       error
+	StatusCode int
+	Host       string
+	Message    string
+	// Hint tells what to do about the error, like the missing scope
+	// of the token
+	Hint string
+	// RetryAfter is how long the API asked to wait before the next
+	// request. Zero if it didn't say.
+	RetryAfter time.Duration
+}
+
+func (err *APIError) Error() string {
+	message := fmt.Sprintf("%s API error: %s", err.Host, err.Kind)
+	if len(err.Message) > 0 {
+		message += ": " + err.Message
+	}
+	if len(err.Hint) > 0 {
+		message += " (" + err.Hint + ")"
+	}
+
+	return message
+}
+
+// Unwrap makes errors.Is(err, ErrNotFound) and friends work
+func (err *APIError) Unwrap() error {
+	return err.Kind
+}
+
+// retryable tells whether repeating the request may succeed. The
+// server errors are only retried for idempotent methods, otherwise a
+// POST that failed after creating the issue would create it twice.
+func (err *APIError) retryable(method string) bool {
+	switch err.Kind {
+	case ErrRateLimited:
+		return true
+	case ErrServerError:
+		return method == "GET" || method == "HEAD" || method == "PUT" || method == "DELETE"
+	default:
+		return false
+	}
+}
+
+// newAPIError makes an APIError out of a 4xx/5xx response. Consumes
+// the body of the response.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := ioutil.ReadAll(resp.Body)
+	message, scope := parseErrorBody(body)
+
+	err := &APIError{
+		StatusCode: resp.StatusCode,
+		Host:       resp.Request.URL.Host,
+		Message:    message,
+		RetryAfter: retryAfter(resp.Header, time.Now()),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		err.Kind = ErrUnauthorized
+		err.Hint = "the token is invalid or expired"
+	case resp.StatusCode == http.StatusTooManyRequests:
+		err.Kind = ErrRateLimited
+	case resp.StatusCode == http.StatusForbidden && (rateLimitExhausted(resp.Header) || err.RetryAfter > 0):
+		// GitHub reports the exceeded rate limits with 403
+		err.Kind = ErrRateLimited
+	case resp.StatusCode == http.StatusForbidden:
+		err.Kind = ErrForbidden
+	case resp.StatusCode == http.StatusNotFound:
+		// GitHub pretends the private repos don't exist for the
+		// tokens without access to them
+		err.Kind = ErrNotFound
+	case resp.StatusCode >= 500:
+		err.Kind = ErrServerError
+	default:
+		err.Kind = ErrInvalidRequest
+	}
+
+	if err.Kind == ErrForbidden || err.Kind == ErrNotFound {
+		if len(scope) == 0 {
+			scope = missingScope(resp.Header)
+		}
+		if len(scope) > 0 {
+			err.Hint = "token lacks " + scope + " scope"
+		}
+	}
+
+	if err.Kind == ErrRateLimited && err.RetryAfter > 0 {
+		err.Hint = "the limit resets at " + time.Now().Add(err.RetryAfter).Format("15:04:05")
+	}
+
+	return err
+}
+
+// parseErrorBody finds the error message in the formats of the
+// supported trackers. GitLab also reports the missing scope.
+func parseErrorBody(body []byte) (string, string) {
+	var response struct {
+		Message          interface{} `json:"message"`
+		Error            interface{} `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+		ErrorMessages    []string    `json:"errorMessages"`
+		Errors           interface{} `json:"errors"`
+		Scope            string      `json:"scope"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return strings.TrimSpace(string(body)), ""
+	}
+
+	scope := ""
+	if response.Error == "insufficient_scope" {
+		scope = response.Scope
+	}
+
+	if message, ok := response.Message.(string); ok && len(message) > 0 {
+		return message, scope
+	}
+
+	if len(response.ErrorDescription) > 0 {
+		return response.ErrorDescription, scope
+	}
+
+	switch e := response.Error.(type) {
+	case string:
+		return e, scope
+	case map[string]interface{}:
+		// Bitbucket
+		if message, ok := e["message"].(string); ok {
+			return message, scope
+		}
+	}
+
+	if len(response.ErrorMessages) > 0 {
+		// Jira
+		return strings.Join(response.ErrorMessages, "; "), scope
+	}
+
+	if e, ok := response.Errors.([]interface{}); ok && len(e) > 0 {
+		// Redmine
+		if message, ok := e[0].(string); ok {
+			return message, scope
+		}
+	}
+
+	return strings.TrimSpace(string(body)), scope
+}
+
+// missingScope compares the OAuth scopes the GitHub endpoint accepts
+// with the ones the token has. Only the classic tokens report them.
+func missingScope(header http.Header) string {
+	if _, ok := header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; !ok {
+		return ""
+	}
+
+	accepted := splitScopes(header.Get("X-Accepted-OAuth-Scopes"))
+	if len(accepted) == 0 {
+		return ""
+	}
+
+	for _, granted := range splitScopes(header.Get("X-OAuth-Scopes")) {
+		for _, scope := range accepted {
+			if granted == scope {
+				return ""
+			}
+		}
+	}
+
+	return strings.Join(accepted, " or ")
+}
+
+func splitScopes(scopes string) []string {
+	result := []string{}
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope = strings.TrimSpace(scope); len(scope) > 0 {
+			result = append(result, scope)
+		}
+	}
+
+	return result
+}
+
+// rateLimitExhausted checks the rate limit headers of GitHub and
+// Gitea (X-RateLimit-*) and GitLab (RateLimit-*)
+func rateLimitExhausted(header http.Header) bool {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if header.Get(prefix+"Remaining") == "0" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryAfter finds out how long to wait before the next request from
+// either Retry-After or the reset time of the exhausted rate limit
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); len(value) > 0 {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+
+		if date, err := http.ParseTime(value); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+
+	if !rateLimitExhausted(header) {
+		return 0
+	}
+
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if reset, err := strconv.ParseInt(header.Get(prefix+"Reset"), 10, 64); err == nil {
+			if resetTime := time.Unix(reset, 0); resetTime.After(now) {
+				return resetTime.Sub(now)
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSleep records the waits of the retries instead of waiting.
+// Returns the recorded waits and the function restoring the sleep.
+func fakeSleep() (*[]time.Duration, func()) {
+	waits := []time.Duration{}
+	oldSleep := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	return &waits, func() { sleep = oldSleep }
+}
+
+func TestQueryHTTP_Retries(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		responses []func(w http.ResponseWriter)
+		waits     []time.Duration
+		kind      error
+	}{
+		{
+			name:   "retry after",
+			method: "POST",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "3")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+			},
+			waits: []time.Duration{3 * time.Second},
+		},
+		{
+			name:   "server error backoff",
+			method: "GET",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			},
+			waits: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:   "server error of post",
+			method: "POST",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+			},
+			waits: []time.Duration{},
+			kind:  ErrServerError,
+		},
+		{
+			name:   "too many server errors",
+			method: "GET",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+			},
+			waits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+			kind:  ErrServerError,
+		},
+		{
+			name:   "unauthorized",
+			method: "GET",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusUnauthorized) },
+			},
+			waits: []time.Duration{},
+			kind:  ErrUnauthorized,
+		},
+		{
+			name:   "github rate limit resets too late",
+			method: "GET",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+					w.WriteHeader(http.StatusForbidden)
+				},
+			},
+			waits: []time.Duration{},
+			kind:  ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits, restore := fakeSleep()
+			defer restore()
+
+			attempt := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				if r.Method == "POST" && string(body) != "{}" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				if attempt < len(tt.responses) {
+					tt.responses[attempt](w)
+				} else {
+					w.Write([]byte(`{"number": 1}`))
+				}
+				attempt++
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v map[string]interface{}
+			err = QueryHTTP(req, &v)
+
+			if tt.kind == nil && err != nil {
+				t.Fatal(err)
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Fatalf("got error %v, want %v", err, tt.kind)
+			}
+
+			if len(*waits) != len(tt.waits) {
+				t.Fatalf("got waits %v, want %v", *waits, tt.waits)
+			}
+			for i := range tt.waits {
+				if (*waits)[i] != tt.waits[i] {
+					t.Fatalf("got waits %v, want %v", *waits, tt.waits)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAPIError_Hints(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		body    string
+		kind    error
+		message string
+		hint    string
+	}{
+		{
+			name:   "github private repo",
+			status: http.StatusNotFound,
+			headers: map[string]string{
+				"X-OAuth-Scopes":          "read:user, gist",
+				"X-Accepted-OAuth-Scopes": "repo",
+			},
+			body:    `{"message": "Not Found", "documentation_url": "https://docs.github.com/rest"}`,
+			kind:    ErrNotFound,
+			message: "Not Found",
+			hint:    "token lacks repo scope",
+		},
+		{
+			name:    "gitlab insufficient scope",
+			status:  http.StatusForbidden,
+			body:    `{"error": "insufficient_scope", "error_description": "The request requires higher privileges than provided by the access token.", "scope": "api"}`,
+			kind:    ErrForbidden,
+			message: "The request requires higher privileges than provided by the access token.",
+			hint:    "token lacks api scope",
+		},
+		{
+			name:    "jira",
+			status:  http.StatusBadRequest,
+			body:    `{"errorMessages": ["Issue type is required"], "errors": {}}`,
+			kind:    ErrInvalidRequest,
+			message: "Issue type is required",
+		},
+		{
+			name:    "bitbucket",
+			status:  http.StatusNotFound,
+			body:    `{"type": "error", "error": {"message": "Repository has no issue tracker."}}`,
+			kind:    ErrNotFound,
+			message: "Repository has no issue tracker.",
+		},
+		{
+			name:    "plain text",
+			status:  http.StatusBadGateway,
+			body:    "Bad Gateway\n",
+			kind:    ErrServerError,
+			message: "Bad Gateway",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			for name, value := range tt.headers {
+				recorder.Header().Set(name, value)
+			}
+			recorder.WriteHeader(tt.status)
+			recorder.WriteString(tt.body)
+
+			resp := recorder.Result()
+			resp.Request = httptest.NewRequest("GET", "https://api.example.com/issues/1", nil)
+
+			err := newAPIError(resp)
+			if err.Kind != tt.kind || err.Message != tt.message || err.Hint != tt.hint {
+				t.Errorf("got %v %q %q, want %v %q %q", err.Kind, err.Message, err.Hint, tt.kind, tt.message, tt.hint)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const azureAPIVersion = "6.0"
+
+var defaultAzureClosedStates = []string{"Closed", "Done", "Removed"}
+
+var azureRemoteRegexps = []*regexp.Regexp{
+	// https://dev.azure.com/<org>/<project>/_git/<repo>
+	regexp.MustCompile(`dev\.azure\.com/([^/]+)/([^/]+)/_git/[^/]+$`),
+	// git@ssh.dev.azure.com:v3/<org>/<project>/<repo>
+	regexp.MustCompile(`ssh\.dev\.azure\.com:v3/([^/]+)/([^/]+)/[^/]+$`),
+	// <org>@vs-ssh.visualstudio.com:v3/<org>/<project>/<repo>
+	regexp.MustCompile(`vs-ssh\.visualstudio\.com:v3/([^/]+)/([^/]+)/[^/]+$`),
+	// https://<org>.visualstudio.com/[DefaultCollection/]<project>/_git/<repo>
+	regexp.MustCompile(`([-\w]+)\.visualstudio\.com/(?:DefaultCollection/)?([^/]+)/_git/[^/]+$`),
+}
+
+// AzureConfig contains the project level configuration of the Azure
+// DevOps work items
+type AzureConfig struct {
+	WorkItemType string   `yaml:"work_item_type"`
+	AreaPath     string   `yaml:"area_path"`
+	ClosedStates []string `yaml:"closed_states"`
+}
+
+// AzureCredentials contains PersonalToken for Azure DevOps API authorization
+type AzureCredentials struct {
+	PersonalToken string
+
+	WorkItemType string
+	AreaPath     string
+	ClosedStates []string
+
+	// apiURL is only overridden in tests
+	apiURL string
+}
+
+func (creds AzureCredentials) baseURL() string {
+	if len(creds.apiURL) > 0 {
+		return creds.apiURL
+	}
+
+	return "https://dev.azure.com"
+}
+
+// configure applies the project level configuration from .snitch.yaml
+func (creds AzureCredentials) configure(project Project, repo string) (string, IssueAPI) {
+	if project.Azure != nil {
+		creds.WorkItemType = project.Azure.WorkItemType
+		creds.AreaPath = project.Azure.AreaPath
+		creds.ClosedStates = project.Azure.ClosedStates
+	}
+
+	return repo, creds
+}
+
+// azureWorkItem is the part of an Azure Boards work item snitch cares about
+type azureWorkItem struct {
+	ID     *int `json:"id"`
+	Fields struct {
+		Title      string `json:"System.Title"`
+		State      string `json:"System.State"`
+		AssignedTo *struct {
+			DisplayName string `json:"displayName"`
+			UniqueName  string `json:"uniqueName"`
+		} `json:"System.AssignedTo"`
+		// Tags are separated with semicolons
+		Tags string `json:"System.Tags"`
+	} `json:"fields"`
+}
+
+func (creds AzureCredentials) normalize(workItem azureWorkItem) (Issue, error) {
+	if workItem.ID == nil {
+		return Issue{}, errMissingField("Azure DevOps", "id")
+	}
+
+	if len(workItem.Fields.State) == 0 {
+		return Issue{}, errMissingField("Azure DevOps", "fields.System.State")
+	}
+
+	closedStates := creds.ClosedStates
+	if len(closedStates) == 0 {
+		closedStates = defaultAzureClosedStates
+	}
+
+	closed := false
+	for _, closedState := range closedStates {
+		if strings.EqualFold(workItem.Fields.State, closedState) {
+			closed = true
+		}
+	}
+
+	var assignees []string
+	if assignedTo := workItem.Fields.AssignedTo; assignedTo != nil {
+		name := assignedTo.UniqueName
+		if len(name) == 0 {
+			name = assignedTo.DisplayName
+		}
+		assignees = append(assignees, name)
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(workItem.Fields.Tags, ";") {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+
+	return Issue{
+		ID:        "#" + strconv.Itoa(*workItem.ID),
+		Title:     workItem.Fields.Title,
+		State:     issueState(closed),
+		Assignees: assignees,
+		Labels:    tags,
+	}, nil
+}
+
+func (creds AzureCredentials) query(ctx context.Context, method, url, contentType string, jsonBody interface{}, v interface{}) error {
+	bodyBuffer := new(bytes.Buffer)
+	err := json.NewEncoder(bodyBuffer).Encode(jsonBody)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyBuffer)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth("", creds.PersonalToken)
+	req.Header.Add("Content-Type", contentType)
+
+	return QueryHTTP(req, v)
+}
+
+// workItemsURL builds the URL of the work items API of the project.
+// repo is <organization>/<project>.
+func (creds AzureCredentials) workItemsURL(repo string, suffix string) string {
+	return creds.baseURL() + "/" + repo + "/_apis/wit/workitems/" + suffix + "?api-version=" + azureAPIVersion
+}
+
+func (creds AzureCredentials) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	id, err := issueNumber(todo)
+	if err != nil {
+		return Issue{}, fmt.Errorf("%s is not a work item ID", *todo.ID)
+	}
+
+	workItem := azureWorkItem{}
+	err = creds.query(ctx, "GET", creds.workItemsURL(repo, strconv.Itoa(id)), "application/json", nil, &workItem)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return creds.normalize(workItem)
+}
+
+func (creds AzureCredentials) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	workItemType := creds.WorkItemType
+	if len(workItemType) == 0 {
+		workItemType = "Task"
+	}
+
+	patch := []map[string]interface{}{
+		{"op": "add", "path": "/fields/System.Title", "value": todo.Title},
+		// The description is HTML
+		{"op": "add", "path": "/fields/System.Description",
+			"value": strings.Replace(html.EscapeString(body), "\n", "<br>", -1)},
+	}
+
+	if len(creds.AreaPath) > 0 {
+		patch = append(patch, map[string]interface{}{
+			"op": "add", "path": "/fields/System.AreaPath", "value": creds.AreaPath,
+		})
+	}
+
+	if len(todo.Assignee) > 0 {
+		patch = append(patch, map[string]interface{}{
+			"op": "add", "path": "/fields/System.AssignedTo", "value": todo.Assignee,
+		})
+	}
+
+	workItem := azureWorkItem{}
+	err := creds.query(ctx,
+		"POST",
+		creds.workItemsURL(repo, "$"+url.PathEscape(workItemType)),
+		"application/json-patch+json",
+		patch,
+		&workItem)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return creds.normalize(workItem)
+}
+
+func (creds AzureCredentials) getHost() string {
+	return "dev.azure.com"
+}
+
+func (creds AzureCredentials) projectURL(repo string) string {
+	return "https://dev.azure.com/" + repo
+}
+
+func (creds AzureCredentials) issueURL(repo string, todo Todo) string {
+	return "https://dev.azure.com/" + repo + "/_workitems/edit/" + strings.TrimPrefix(*todo.ID, "#")
+}
+
+// matchRemote extracts <organization>/<project> from the Azure Repos
+// remote URLs since they don't follow the <host>/<owner>/<repo> layout
+func (creds AzureCredentials) matchRemote(remoteURL string) (string, bool) {
+	for _, remoteRegexp := range azureRemoteRegexps {
+		if groups := remoteRegexp.FindStringSubmatch(remoteURL); groups != nil {
+			project, err := url.PathUnescape(groups[2])
+			if err != nil {
+				project = groups[2]
+			}
+			return groups[1] + "/" + url.PathEscape(project), true
+		}
+	}
+
+	return "", false
+}
+
+// AzureCredentialsFromFile gets AzureCredentials from a filepath
+func AzureCredentialsFromFile(filepath string) (AzureCredentials, error) {
+	cfg, err := ini.Load(filepath)
+	if err != nil {
+		return AzureCredentials{}, err
+	}
+
+	return AzureCredentials{
+		PersonalToken: cfg.Section("dev.azure.com").Key("personal_token").String(),
+	}, nil
+}
+
+func getAzureCredentials(creds []IssueAPI) []IssueAPI {
+	tokenEnvar := os.Getenv("AZURE_DEVOPS_TOKEN")
+	xdgEnvar := os.Getenv("XDG_CONFIG_HOME")
+	usr, err := user.Current()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(tokenEnvar) != 0 {
+		creds = append(creds, AzureCredentials{PersonalToken: tokenEnvar})
+	}
+
+	// custom XDG_CONFIG_HOME
+	if len(xdgEnvar) != 0 {
+		filePath := path.Join(xdgEnvar, "snitch/azure.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			if cred, err := AzureCredentialsFromFile(filePath); err == nil {
+				creds = append(creds, cred)
+			}
+		}
+	}
+
+	// default XDG_CONFIG_HOME
+	if len(xdgEnvar) == 0 {
+		filePath := path.Join(usr.HomeDir, ".config/snitch/azure.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			if cred, err := AzureCredentialsFromFile(filePath); err == nil {
+				creds = append(creds, cred)
+			}
+		}
+	}
+
+	filePath := path.Join(usr.HomeDir, ".snitch/azure.ini")
+	if _, err := os.Stat(filePath); err == nil {
+		if cred, err := AzureCredentialsFromFile(filePath); err == nil {
+			creds = append(creds, cred)
+		}
+	}
+
+	return creds
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureCredentials_MatchRemote(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"https://dev.azure.com/contoso/Fabrikam/_git/snitch", "contoso/Fabrikam"},
+		{"https://contoso@dev.azure.com/contoso/Fabrikam%20Fiber/_git/snitch", "contoso/Fabrikam%20Fiber"},
+		{"git@ssh.dev.azure.com:v3/contoso/Fabrikam/snitch", "contoso/Fabrikam"},
+		{"contoso@vs-ssh.visualstudio.com:v3/contoso/Fabrikam/snitch", "contoso/Fabrikam"},
+		{"https://contoso.visualstudio.com/Fabrikam/_git/snitch", "contoso/Fabrikam"},
+		{"https://contoso.visualstudio.com/DefaultCollection/Fabrikam/_git/snitch", "contoso/Fabrikam"},
+		{"git@github.com:tsoding/snitch", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, _ := AzureCredentials{}.matchRemote(tt.in)
+			if got != tt.out {
+				t.Errorf("got %q, want %q", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestAzureCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, token, ok := r.BasicAuth(); !ok || token != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/contoso/Fabrikam/_apis/wit/workitems/$Bug":
+			patch := []struct {
+				Op    string
+				Path  string
+				Value string
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil ||
+				r.Header.Get("Content-Type") != "application/json-patch+json" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for _, op := range patch {
+				if op.Path == "/fields/System.AreaPath" && op.Value != `Fabrikam\Team` {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+			}
+			w.Write([]byte(`{"id": 42, "fields": {"System.State": "New"}}`))
+		case r.Method == "GET" && r.URL.Path == "/contoso/Fabrikam/_apis/wit/workitems/42":
+			w.Write([]byte(`{"id": 42, "fields": {"System.State": "Done"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	_, creds := AzureCredentials{PersonalToken: "secret", apiURL: server.URL}.configure(Project{
+		Azure: &AzureConfig{
+			WorkItemType: "Bug",
+			AreaPath:     `Fabrikam\Team`,
+		},
+	}, "contoso/Fabrikam")
+
+	todo, err := Todo{Title: "Rewrite this in Rust"}.Report(context.Background(), creds, "contoso/Fabrikam", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := derefString(todo.ID); got != "#42" {
+		t.Errorf("got ID %q, want %q", got, "#42")
+	}
+
+	status, err := todo.RetrieveStatus(context.Background(), creds, "contoso/Fabrikam", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != "closed" {
+		t.Errorf("got status %q, want %q", status, "closed")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func todosWithIDs(ids ...string) []Todo {
+	todos := []Todo{}
+	for _, id := range ids {
+		todos = append(todos, Todo{ID: stringPtr(id)})
+	}
+	return todos
+}
+
+func TestGetIssues_Gitlab(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/api/v4/projects/alice/snitch/issues" || r.URL.Query().Get("state") != "all" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		issues := []map[string]interface{}{}
+		for _, iid := range r.URL.Query()["iids[]"] {
+			number, _ := strconv.Atoi(iid)
+			// Every third issue is closed, the ones above 200 don't exist
+			if number > 200 {
+				continue
+			}
+			state := "opened"
+			if number%3 == 0 {
+				state = "closed"
+			}
+			issues = append(issues, map[string]interface{}{"iid": number, "state": state})
+		}
+		json.NewEncoder(w).Encode(issues)
+	}))
+	defer server.Close()
+
+	ids := []string{}
+	for i := 1; i <= 150; i++ {
+		ids = append(ids, "#"+strconv.Itoa(i))
+	}
+	// Duplicates are asked once
+	ids = append(ids, "#3", "#6")
+
+	creds := GitlabCredentials{Host: "gitlab.example.com", Instance: Instance{BaseURL: server.URL}}
+	issues, err := getIssues(context.Background(), creds, "alice/snitch", todosWithIDs(ids...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issues) != 150 || requests != 2 {
+		t.Fatalf("got %d issues in %d requests, want 150 in 2", len(issues), requests)
+	}
+
+	if issues["#3"].State != issueClosed || issues["#4"].State != issueOpen {
+		t.Errorf("unexpected states %v %v", issues["#3"], issues["#4"])
+	}
+
+	requests = 0
+	issues, err = getIssues(context.Background(), creds, "alice/snitch", todosWithIDs("#1", "#201"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The missing one is asked on its own and is not found
+	if _, ok := issues["#201"]; ok || len(issues) != 1 || requests != 2 {
+		t.Errorf("got %v in %d requests", issues, requests)
+	}
+}
+
+func TestGetIssues_Gitea(t *testing.T) {
+	const total = 120
+	listRequests, issueRequests := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/version":
+			w.Write([]byte(`{"version": "1.21.4"}`))
+		case "/api/v1/repos/alice/snitch/issues":
+			listRequests++
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+			// Newest first
+			issues := []map[string]interface{}{}
+			for number := total - (page-1)*limit; number > 0 && number > total-page*limit; number-- {
+				state := "open"
+				if number%2 == 0 {
+					state = "closed"
+				}
+				issues = append(issues, map[string]interface{}{"number": number, "state": state})
+			}
+			json.NewEncoder(w).Encode(issues)
+		default:
+			issueRequests++
+			number, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/repos/alice/snitch/issues/"))
+			if number < 1 || number > total {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"message": "issue does not exist"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"number": %d, "state": "open"}`, number)
+		}
+	}))
+	defer server.Close()
+
+	creds := GiteaCredentials{Host: "gitea.example.com", Instance: Instance{BaseURL: server.URL}}
+	issues, err := getIssues(context.Background(), creds, "alice/snitch", todosWithIDs("#119", "#110", "#100", "#90", "#80", "#60", "#121"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// #60 is on the 2nd page, no need for the 3rd one. #121 doesn't
+	// exist and is asked on its own.
+	if len(issues) != 6 || listRequests != 2 || issueRequests != 1 {
+		t.Fatalf("got %v in %d list and %d issue requests", issues, listRequests, issueRequests)
+	}
+
+	if issues["#60"].State != issueClosed || issues["#119"].State != issueOpen {
+		t.Errorf("unexpected states %v %v", issues["#60"], issues["#119"])
+	}
+
+	// Listing down to #1 takes more pages than asking for both issues
+	listRequests, issueRequests = 0, 0
+	issues, err = getIssues(context.Background(), creds, "alice/snitch", todosWithIDs("#1", "#5000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issues) != 1 || listRequests != 0 || issueRequests != 2 {
+		t.Errorf("got %v in %d list and %d issue requests", issues, listRequests, issueRequests)
+	}
+}
+
+func TestGetIssues_Github(t *testing.T) {
+	aliasRegexp := regexp.MustCompile(`(i\d+): issueOrPullRequest\(number: (\d+)\)`)
+	graphqlRequests, issueRequests := 0, 0
+	graphqlFails := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/graphql" {
+			graphqlRequests++
+			if graphqlFails {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message": "Resource not accessible by integration"}`)
+				return
+			}
+
+			request := struct {
+				Query     string
+				Variables map[string]string
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil ||
+				request.Variables["owner"] != "alice" || request.Variables["name"] != "snitch" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			repository := map[string]interface{}{}
+			errs := []map[string]string{}
+			for _, groups := range aliasRegexp.FindAllStringSubmatch(request.Query, -1) {
+				number, _ := strconv.Atoi(groups[2])
+				switch number {
+				case 1:
+					repository[groups[1]] = map[string]interface{}{"number": 1, "title": "Open issue", "state": "OPEN",
+						"assignees": map[string]interface{}{"nodes": []map[string]string{{"login": "bob"}}}}
+				case 2:
+					repository[groups[1]] = map[string]interface{}{"number": 2, "title": "Closed issue", "state": "CLOSED"}
+				case 3:
+					repository[groups[1]] = map[string]interface{}{"number": 3, "title": "Merged pull request", "state": "MERGED"}
+				default:
+					repository[groups[1]] = nil
+					errs = append(errs, map[string]string{
+						"type":    "NOT_FOUND",
+						"message": "Could not resolve to an issue or pull request with the number of " + groups[2] + ".",
+					})
+				}
+			}
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data":   map[string]interface{}{"repository": repository},
+				"errors": errs,
+			})
+			return
+		}
+
+		issueRequests++
+		switch r.URL.Path {
+		case "/repos/alice/snitch/issues/1":
+			fmt.Fprint(w, `{"number": 1, "title": "Open issue", "state": "open"}`)
+		case "/repos/alice/snitch/issues/2":
+			fmt.Fprint(w, `{"number": 2, "title": "Closed issue", "state": "closed"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	creds := GithubCredentials{Host: githubHost, restURL: server.URL}
+	issues, err := getIssues(context.Background(), creds, "alice/snitch", todosWithIDs("#1", "#2", "#3", "#4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// #4 is asked on its own once the query reports it missing
+	if len(issues) != 3 || graphqlRequests != 1 || issueRequests != 1 {
+		t.Fatalf("got %v in %d GraphQL and %d REST requests", issues, graphqlRequests, issueRequests)
+	}
+
+	if issues["#1"].State != issueOpen || issues["#2"].State != issueClosed || issues["#3"].State != issueClosed {
+		t.Errorf("unexpected states %v %v %v", issues["#1"], issues["#2"], issues["#3"])
+	}
+
+	if len(issues["#1"].Assignees) != 1 || issues["#1"].Assignees[0] != "bob" {
+		t.Errorf("got assignees %v, want [bob]", issues["#1"].Assignees)
+	}
+
+	// The issues are asked one by one when the query fails
+	graphqlFails = true
+	graphqlRequests, issueRequests = 0, 0
+	issues, err = getIssues(context.Background(), creds, "alice/snitch", todosWithIDs("#1", "#2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issues) != 2 || graphqlRequests != 1 || issueRequests != 2 {
+		t.Errorf("got %v in %d GraphQL and %d REST requests", issues, graphqlRequests, issueRequests)
+	}
+
+	// but not when the request is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	graphqlRequests, issueRequests = 0, 0
+	if _, err := getIssues(ctx, creds, "alice/snitch", todosWithIDs("#1", "#2")); err == nil || issueRequests != 0 {
+		t.Errorf("got %v after %d REST requests, want the cancellation", err, issueRequests)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const bitbucketAPIURL = "https://api.bitbucket.org/2.0"
+
+// bitbucketClosedStates are the Bitbucket issue states that mean the
+// issue is not going to be worked on anymore
+var bitbucketClosedStates = []string{"resolved", "invalid", "duplicate", "wontfix", "closed"}
+
+// BitbucketCredentials contains either Username and AppPassword or
+// AccessToken for Bitbucket Cloud API authorization
+type BitbucketCredentials struct {
+	Username    string
+	AppPassword string
+	AccessToken string
+
+	// apiURL is only overridden in tests
+	apiURL string
+}
+
+func (creds BitbucketCredentials) baseURL() string {
+	if len(creds.apiURL) > 0 {
+		return creds.apiURL
+	}
+
+	return bitbucketAPIURL
+}
+
+// bitbucketIssue is the part of a Bitbucket issue snitch cares about
+type bitbucketIssue struct {
+	ID       *int   `json:"id"`
+	Title    string `json:"title"`
+	State    string `json:"state"`
+	Assignee *struct {
+		Nickname    string `json:"nickname"`
+		DisplayName string `json:"display_name"`
+	} `json:"assignee"`
+	// Kind is bug, enhancement, proposal or task. Bitbucket has no
+	// labels, so it stands in for them.
+	Kind string `json:"kind"`
+}
+
+func (issue bitbucketIssue) normalize() (Issue, error) {
+	if issue.ID == nil {
+		return Issue{}, errMissingField("Bitbucket", "id")
+	}
+
+	if len(issue.State) == 0 {
+		return Issue{}, errMissingField("Bitbucket", "state")
+	}
+
+	// Bitbucket has a lot of states. Bringing them down to what
+	// the rest of snitch understands.
+	closed := false
+	for _, closedState := range bitbucketClosedStates {
+		if issue.State == closedState {
+			closed = true
+		}
+	}
+
+	var assignees []string
+	if issue.Assignee != nil {
+		name := issue.Assignee.Nickname
+		if len(name) == 0 {
+			name = issue.Assignee.DisplayName
+		}
+		assignees = append(assignees, name)
+	}
+
+	var labels []string
+	if len(issue.Kind) > 0 {
+		labels = append(labels, issue.Kind)
+	}
+
+	return Issue{
+		ID:        "#" + strconv.Itoa(*issue.ID),
+		Title:     issue.Title,
+		State:     issueState(closed),
+		Assignees: assignees,
+		Labels:    labels,
+	}, nil
+}
+
+func (creds BitbucketCredentials) query(ctx context.Context, method, url string, jsonBody map[string]interface{}, v interface{}) error {
+	bodyBuffer := new(bytes.Buffer)
+	err := json.NewEncoder(bodyBuffer).Encode(jsonBody)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyBuffer)
+	if err != nil {
+		return err
+	}
+
+	if len(creds.AccessToken) > 0 {
+		req.Header.Add("Authorization", "Bearer "+creds.AccessToken)
+	} else {
+		req.SetBasicAuth(creds.Username, creds.AppPassword)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	return QueryHTTP(req, v)
+}
+
+// issueAPIURL is the URL of the issue in the API
+func (creds BitbucketCredentials) issueAPIURL(repo string, number int) string {
+	return creds.baseURL() + "/repositories/" + repo + "/issues/" + strconv.Itoa(number)
+}
+
+func (creds BitbucketCredentials) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	issue := bitbucketIssue{}
+	err = creds.query(ctx, "GET", creds.issueAPIURL(repo, number), nil, &issue)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return issue.normalize()
+}
+
+func (creds BitbucketCredentials) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	params := map[string]interface{}{
+		"title": todo.Title,
+		"content": map[string]interface{}{
+			"raw": body,
+		},
+	}
+	if len(todo.Assignee) > 0 {
+		params["assignee"] = map[string]interface{}{
+			"account_id": todo.Assignee,
+		}
+	}
+
+	issue := bitbucketIssue{}
+	err := creds.query(ctx,
+		"POST",
+		creds.baseURL()+"/repositories/"+repo+"/issues",
+		params,
+		&issue)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return issue.normalize()
+}
+
+func (creds BitbucketCredentials) getHost() string {
+	return "bitbucket.org"
+}
+
+// BitbucketCredentialsFromFile gets BitbucketCredentials from a filepath
+func BitbucketCredentialsFromFile(filepath string) (BitbucketCredentials, error) {
+	cfg, err := ini.Load(filepath)
+	if err != nil {
+		return BitbucketCredentials{}, err
+	}
+
+	section := cfg.Section("bitbucket.org")
+
+	return BitbucketCredentials{
+		Username:    section.Key("username").String(),
+		AppPassword: section.Key("app_password").String(),
+		AccessToken: section.Key("access_token").String(),
+	}, nil
+}
+
+// BitbucketCredentialsFromToken returns a BitbucketCredentials from
+// either `<username>:<app-password>` or `<access-token>` string
+func BitbucketCredentialsFromToken(token string) (BitbucketCredentials, error) {
+	credentials := strings.Split(token, ":")
+
+	switch len(credentials) {
+	case 1:
+		return BitbucketCredentials{
+			AccessToken: credentials[0],
+		}, nil
+	case 2:
+		return BitbucketCredentials{
+			Username:    credentials[0],
+			AppPassword: credentials[1],
+		}, nil
+	default:
+		return BitbucketCredentials{},
+			fmt.Errorf("Couldn't parse Bitbucket credentials from ENV: %s", token)
+	}
+}
+
+func getBitbucketCredentials(creds []IssueAPI) []IssueAPI {
+	tokenEnvar := os.Getenv("BITBUCKET_TOKEN")
+	xdgEnvar := os.Getenv("XDG_CONFIG_HOME")
+	usr, err := user.Current()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(tokenEnvar) != 0 {
+		parsedCredentials, err := BitbucketCredentialsFromToken(tokenEnvar)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			creds = append(creds, parsedCredentials)
+		}
+	}
+
+	// custom XDG_CONFIG_HOME
+	if len(xdgEnvar) != 0 {
+		filePath := path.Join(xdgEnvar, "snitch/bitbucket.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			if cred, err := BitbucketCredentialsFromFile(filePath); err == nil {
+				creds = append(creds, cred)
+			}
+		}
+	}
+
+	// default XDG_CONFIG_HOME
+	if len(xdgEnvar) == 0 {
+		filePath := path.Join(usr.HomeDir, ".config/snitch/bitbucket.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			if cred, err := BitbucketCredentialsFromFile(filePath); err == nil {
+				creds = append(creds, cred)
+			}
+		}
+	}
+
+	filePath := path.Join(usr.HomeDir, ".snitch/bitbucket.ini")
+	if _, err := os.Stat(filePath); err == nil {
+		if cred, err := BitbucketCredentialsFromFile(filePath); err == nil {
+			creds = append(creds, cred)
+		}
+	}
+
+	return creds
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBitbucketCredentials(t *testing.T) {
+	issues := map[string]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "alice" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/repositories/alice/snitch/issues":
+			var issue struct {
+				Title   string
+				Content struct {
+					Raw string
+				}
+			}
+			if err := json.NewDecoder(r.Body).Decode(&issue); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			issues["1"] = issue.Title
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 1, "state": "new"}`))
+		case r.Method == "GET" && r.URL.Path == "/repositories/alice/snitch/issues/1":
+			w.Write([]byte(`{"id": 1, "state": "resolved"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	creds := BitbucketCredentials{
+		Username:    "alice",
+		AppPassword: "secret",
+		apiURL:      server.URL,
+	}
+
+	todo, err := Todo{Title: "Rewrite this in Rust"}.Report(context.Background(), creds, "alice/snitch", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if todo.ID == nil || *todo.ID != "#1" {
+		t.Fatalf("got ID %q, want %q", derefString(todo.ID), "#1")
+	}
+
+	if issues["1"] != "Rewrite this in Rust" {
+		t.Errorf("got title %q, want %q", issues["1"], "Rewrite this in Rust")
+	}
+
+	status, err := todo.RetrieveStatus(context.Background(), creds, "alice/snitch", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != "closed" {
+		t.Errorf("got status %q, want %q", status, "closed")
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// BitbucketServerCredentials contains the Jira credentials of the repos
+// hosted on a Bitbucket Server/Data Center instance. Bitbucket Server
+// has no issue tracker of its own, its repos track the issues in the
+// linked Jira. The issues go to the Jira project with the key of the
+// Bitbucket project of the repo unless JiraProject says otherwise.
+type BitbucketServerCredentials struct {
+	// Host is the host of the Bitbucket Server instance the remotes
+	// point to
+	Host        string
+	JiraProject string
+	Jira        JiraCredentials
+}
+
+// jiraProject is the key of the Jira project the issues of the repo go
+// to. The repo is <project-key>/<repo-slug>.
+func (creds BitbucketServerCredentials) jiraProject(repo string) (string, error) {
+	if len(creds.JiraProject) > 0 {
+		return creds.JiraProject, nil
+	}
+
+	key := strings.Split(repo, "/")[0]
+	if strings.HasPrefix(key, "~") {
+		return "", fmt.Errorf("%s is a personal repo and doesn't belong to any Jira project. Set jira_project for %s", repo, creds.Host)
+	}
+
+	return strings.ToUpper(key), nil
+}
+
+func (creds BitbucketServerCredentials) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	project, err := creds.jiraProject(repo)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return creds.Jira.getIssue(ctx, project, todo)
+}
+
+func (creds BitbucketServerCredentials) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	project, err := creds.jiraProject(repo)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return creds.Jira.postIssue(ctx, project, todo, body)
+}
+
+func (creds BitbucketServerCredentials) getHost() string {
+	return creds.Host
+}
+
+func (creds BitbucketServerCredentials) projectURL(repo string) string {
+	project, err := creds.jiraProject(repo)
+	if err != nil {
+		return "https://" + creds.Jira.Host
+	}
+
+	return creds.Jira.projectURL(project)
+}
+
+func (creds BitbucketServerCredentials) issueURL(repo string, todo Todo) string {
+	return creds.Jira.issueURL(repo, todo)
+}
+
+// matchRemote extracts <project-key>/<repo-slug> from the http remotes
+// like https://<host>/scm/<project-key>/<repo-slug> (optionally behind
+// a context path) and the ssh remotes like
+// ssh://git@<host>:7999/<project-key>/<repo-slug>. The personal repos
+// have ~<user> in place of the project key.
+func (creds BitbucketServerCredentials) matchRemote(remoteURL string) (string, bool) {
+	remoteRegexp := regexp.MustCompile(`(?:^|[@/])` + regexp.QuoteMeta(creds.Host) +
+		`(?::\d+)?/(?:[^/]+/)*?(?:scm/)?(~?[-.\w]+)/([-.\w]+)/?$`)
+
+	groups := remoteRegexp.FindStringSubmatch(strings.TrimSuffix(remoteURL, ".git"))
+	if groups == nil {
+		return "", false
+	}
+
+	repo := groups[1] + "/" + groups[2]
+	if !isSafeRepo(repo) {
+		return "", false
+	}
+
+	return repo, true
+}
+
+// BitbucketServerCredentialsFromFile gets BitbucketServerCredentials
+// from a filepath. The sections are the hosts of the Bitbucket Server
+// instances.
+func BitbucketServerCredentialsFromFile(filepath string) []BitbucketServerCredentials {
+	credentials := []BitbucketServerCredentials{}
+
+	cfg, err := ini.Load(filepath)
+	if err != nil {
+		return credentials
+	}
+
+	for _, section := range cfg.Sections()[1:] {
+		credentials = append(credentials, BitbucketServerCredentials{
+			Host:        section.Name(),
+			JiraProject: section.Key("jira_project").String(),
+			Jira: JiraCredentials{
+				Host:          section.Key("jira_host").String(),
+				Email:         section.Key("jira_email").String(),
+				APIToken:      section.Key("jira_api_token").String(),
+				PersonalToken: section.Key("jira_personal_token").String(),
+				IssueType:     section.Key("jira_issue_type").String(),
+			},
+		})
+	}
+
+	return credentials
+}
+
+// BitbucketServerCredentialsFromToken returns a
+// BitbucketServerCredentials from a
+// `<bitbucket-host>:<jira-host>:<jira-personal-token>` string
+func BitbucketServerCredentialsFromToken(token string) (BitbucketServerCredentials, error) {
+	credentials := strings.SplitN(token, ":", 3)
+	if len(credentials) != 3 {
+		return BitbucketServerCredentials{},
+			fmt.Errorf("Couldn't parse Bitbucket Server credentials from ENV: %s", token)
+	}
+
+	return BitbucketServerCredentials{
+		Host: credentials[0],
+		Jira: JiraCredentials{
+			Host:          credentials[1],
+			PersonalToken: credentials[2],
+		},
+	}, nil
+}
+
+func getBitbucketServerCredentials(creds []IssueAPI) []IssueAPI {
+	tokenEnvar := os.Getenv("BITBUCKET_SERVER_TOKEN")
+	xdgEnvar := os.Getenv("XDG_CONFIG_HOME")
+	usr, err := user.Current()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(tokenEnvar) != 0 {
+		for _, credential := range strings.Split(tokenEnvar, ",") {
+			parsedCredentials, err := BitbucketServerCredentialsFromToken(credential)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			creds = append(creds, parsedCredentials)
+		}
+	}
+
+	// custom XDG_CONFIG_HOME
+	if len(xdgEnvar) != 0 {
+		filePath := path.Join(xdgEnvar, "snitch/bitbucket-server.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			for _, cred := range BitbucketServerCredentialsFromFile(filePath) {
+				creds = append(creds, cred)
+			}
+		}
+	}
+
+	// default XDG_CONFIG_HOME
+	if len(xdgEnvar) == 0 {
+		filePath := path.Join(usr.HomeDir, ".config/snitch/bitbucket-server.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			for _, cred := range BitbucketServerCredentialsFromFile(filePath) {
+				creds = append(creds, cred)
+			}
+		}
+	}
+
+	filePath := path.Join(usr.HomeDir, ".snitch/bitbucket-server.ini")
+	if _, err := os.Stat(filePath); err == nil {
+		for _, cred := range BitbucketServerCredentialsFromFile(filePath) {
+			creds = append(creds, cred)
+		}
+	}
+
+	return creds
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBitbucketServerCredentials_MatchRemote(t *testing.T) {
+	creds := BitbucketServerCredentials{Host: "bitbucket.example.com"}
+
+	tests := []struct {
+		in     string
+		want   string
+		wantOk bool
+	}{
+		{"https://bitbucket.example.com/scm/proj/snitch.git", "proj/snitch", true},
+		{"https://alice@bitbucket.example.com/scm/PROJ/snitch.git", "PROJ/snitch", true},
+		{"https://bitbucket.example.com:8443/bitbucket/scm/proj/snitch.git", "proj/snitch", true},
+		{"ssh://git@bitbucket.example.com:7999/proj/snitch.git", "proj/snitch", true},
+		{"ssh://git@bitbucket.example.com:7999/~alice/snitch.git", "~alice/snitch", true},
+		{"https://bitbucket.example.com/scm/proj/../snitch.git", "", false},
+		{"ssh://git@notbitbucket.example.com:7999/proj/snitch.git", "", false},
+		{"git@bitbucket.org:alice/snitch.git", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := matchRepo(creds, tt.in)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("got %q %v, want %q %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestBitbucketServerCredentials(t *testing.T) {
+	projects := map[string]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/rest/api/2/issue":
+			var issue struct {
+				Fields struct {
+					Project struct{ Key string }
+					Summary string
+				}
+			}
+			if err := json.NewDecoder(r.Body).Decode(&issue); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			projects[issue.Fields.Summary] = issue.Fields.Project.Key
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "10000", "key": "` + issue.Fields.Project.Key + `-1"}`))
+		case r.Method == "GET" && r.URL.Path == "/rest/api/2/issue/PROJ-1":
+			w.Write([]byte(`{"key": "PROJ-1", "fields": {"summary": "Rewrite this in Rust", "status": {"name": "Done", "statusCategory": {"key": "done"}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	creds := BitbucketServerCredentials{
+		Host: "bitbucket.example.com",
+		Jira: JiraCredentials{
+			Host:          "jira.example.com",
+			PersonalToken: "secret",
+			apiURL:        server.URL,
+		},
+	}
+
+	todo, err := Todo{Title: "Rewrite this in Rust"}.Report(context.Background(), creds, "proj/snitch", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := derefString(todo.ID); got != "PROJ-1" {
+		t.Errorf("got ID %q, want %q", got, "PROJ-1")
+	}
+
+	status, err := todo.RetrieveStatus(context.Background(), creds, "proj/snitch", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != issueClosed {
+		t.Errorf("got status %q, want %q", status, issueClosed)
+	}
+
+	if got := issueURL(creds, "proj/snitch", todo); got != "https://jira.example.com/browse/PROJ-1" {
+		t.Errorf("got link %q", got)
+	}
+
+	// The personal repos need the Jira project to be set explicitly
+	if _, err := (Todo{Title: "And then in Zig"}).Report(context.Background(), creds, "~alice/snitch", "body"); err == nil {
+		t.Errorf("reported the issue of a personal repo without jira_project")
+	}
+
+	creds.JiraProject = "TOOLS"
+	if _, err := (Todo{Title: "And then in Zig"}).Report(context.Background(), creds, "~alice/snitch", "body"); err != nil {
+		t.Fatal(err)
+	}
+	if projects["And then in Zig"] != "TOOLS" {
+		t.Errorf("got project %q, want %q", projects["And then in Zig"], "TOOLS")
+	}
+}
+
+func TestBitbucketServerCredentialsFromToken(t *testing.T) {
+	creds, err := BitbucketServerCredentialsFromToken("bitbucket.example.com:jira.example.com:secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if creds.Host != "bitbucket.example.com" || creds.Jira.Host != "jira.example.com" || creds.Jira.PersonalToken != "secret" {
+		t.Errorf("got %+v", creds)
+	}
+
+	if _, err := BitbucketServerCredentialsFromToken("bitbucket.example.com:secret"); err == nil {
+		t.Errorf("parsed the token without the Jira host")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const uncommittedSha = "0000000000000000000000000000000000000000"
+
+// Blame contains information about the commit that introduced a line
+type Blame struct {
+	Author      string
+	AuthorEmail string
+	Commit      string
+	Time        time.Time
+}
+
+// IsCommitted checks whether the line is already committed
+func (blame Blame) IsCommitted() bool {
+	return blame.Commit != uncommittedSha
+}
+
+// Age returns how long ago the line was introduced
+func (blame Blame) Age() time.Duration {
+	return time.Since(blame.Time)
+}
+
+func formatAge(age time.Duration) string {
+	days := int(age.Hours() / 24)
+
+	count, unit := days, "day"
+	switch {
+	case days >= 365:
+		count, unit = days/365, "year"
+	case days >= 30:
+		count, unit = days/30, "month"
+	case days < 1:
+		return "today"
+	}
+
+	if count > 1 {
+		unit = unit + "s"
+	}
+
+	return fmt.Sprintf("%d %s ago", count, unit)
+}
+
+func (blame Blame) String() string {
+	if !blame.IsCommitted() {
+		return "not committed yet"
+	}
+
+	return fmt.Sprintf("%s <%s> %.8s %s",
+		blame.Author, blame.AuthorEmail, blame.Commit, formatAge(blame.Age()))
+}
+
+// ParseBlamePorcelain parses the output of `git blame --porcelain`
+// for a single line
+func ParseBlamePorcelain(output []byte) (*Blame, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("git blame output is empty")
+	}
+
+	header := strings.Fields(scanner.Text())
+	if len(header) == 0 {
+		return nil, fmt.Errorf("unexpected git blame output: %s", scanner.Text())
+	}
+
+	blame := &Blame{Commit: header[0]}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "\t") {
+			break
+		}
+
+		key, value := line, ""
+		if space := strings.IndexByte(line, ' '); space >= 0 {
+			key, value = line[:space], line[space+1:]
+		}
+
+		switch key {
+		case "author":
+			blame.Author = value
+		case "author-mail":
+			blame.AuthorEmail = strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+		case "author-time":
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			blame.Time = time.Unix(seconds, 0)
+		}
+	}
+
+	return blame, nil
+}
+
+// GitBlame finds out who introduced the line of the todo
+func (todo Todo) GitBlame() (*Blame, error) {
+	cmd := exec.Command("git", "blame", "--porcelain",
+		"-L", fmt.Sprintf("%d,%d", todo.Line, todo.Line),
+		"--", todo.Filename)
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git blame failed for %s:%d: %s",
+			todo.Filename, todo.Line, strings.TrimSpace(errb.String()))
+	}
+
+	return ParseBlamePorcelain(outb.Bytes())
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBlamePorcelain(t *testing.T) {
+	output := "1963024458d7a3a2a0b1b8c1e0f4f21a9e0a1b2c 3 3 1\n" +
+		"author Alice\n" +
+		"author-mail <alice@example.com>\n" +
+		"author-time 1600000000\n" +
+		"author-tz +0000\n" +
+		"summary Add todo\n" +
+		"filename main.go\n" +
+		"\t// TODO: rewrite this in Rust\n"
+
+	blame, err := ParseBlamePorcelain([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Blame{
+		Author:      "Alice",
+		AuthorEmail: "alice@example.com",
+		Commit:      "1963024458d7a3a2a0b1b8c1e0f4f21a9e0a1b2c",
+		Time:        time.Unix(1600000000, 0),
+	}
+
+	if *blame != want {
+		t.Errorf("got %+v, want %+v", *blame, want)
+	}
+
+	project := Project{Assignees: map[string]string{"Alice@Example.com": "alice"}}
+	if got := project.AssigneeOf(blame); got != "alice" {
+		t.Errorf("got assignee %q, want %q", got, "alice")
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultCacheTTL is how long the cached state of an issue is trusted
+// without asking the tracker at all
+const defaultCacheTTL = 5 * time.Minute
+
+// The issues nobody asks about anymore are dropped from the cache after
+// cacheRetentionTTLs of the TTL, but not sooner than minCacheRetention.
+// The validators of the conditional requests stay useful much longer
+// than the TTL.
+const (
+	cacheRetentionTTLs = 1000
+	minCacheRetention  = 7 * 24 * time.Hour
+)
+
+// errNotModified is returned by the conditional requests when the
+// cached issue is still up to date
+var errNotModified = errors.New("not modified")
+
+// CachedIssue is the state of an issue as of Checked along with the
+// validators of the conditional requests
+type CachedIssue struct {
+	Issue        Issue     `json:"issue"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Checked      time.Time `json:"checked"`
+}
+
+// IssueCache keeps the states of the issues between the runs, keyed by
+// <host>/<repo>/<id>
+type IssueCache struct {
+	TTL    time.Duration
+	Issues map[string]CachedIssue
+	// RevalidateClosed makes the closed issues asked again no matter
+	// how fresh they are. The issue may have been reopened within the
+	// TTL and removing its TODO can't be undone.
+	RevalidateClosed bool
+
+	path  string
+	dirty bool
+}
+
+// conditionalRequest carries the validators of the cached issue to
+// QueryHTTPWithClient and the new ones back
+type conditionalRequest struct {
+	ETag         string
+	LastModified string
+}
+
+type conditionalRequestKey struct{}
+
+func withConditionalRequest(ctx context.Context, conditional *conditionalRequest) context.Context {
+	return context.WithValue(ctx, conditionalRequestKey{}, conditional)
+}
+
+func conditionalRequestFrom(ctx context.Context) *conditionalRequest {
+	conditional, _ := ctx.Value(conditionalRequestKey{}).(*conditionalRequest)
+	return conditional
+}
+
+// issueCachePath is $XDG_CACHE_HOME/snitch/issues.json or its
+// equivalent on the other platforms
+func issueCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "snitch", "issues.json"), nil
+}
+
+// loadIssueCache reads the cache. A missing or broken cache is empty.
+func loadIssueCache(cachePath string, ttl time.Duration) *IssueCache {
+	cache := &IssueCache{
+		TTL:    ttl,
+		Issues: map[string]CachedIssue{},
+		path:   cachePath,
+	}
+
+	content, err := ioutil.ReadFile(cachePath)
+	if err != nil {
+		return cache
+	}
+
+	if err := json.Unmarshal(content, &cache.Issues); err != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] Ignoring the broken cache %s: %s\n", cachePath, err)
+		cache.Issues = map[string]CachedIssue{}
+	}
+
+	return cache
+}
+
+// fresh tells whether the cached issue can be used without asking the
+// tracker
+func (cache *IssueCache) fresh(cached CachedIssue) bool {
+	if cache.RevalidateClosed && cached.Issue.State == issueClosed {
+		return false
+	}
+
+	return time.Since(cached.Checked) < cache.TTL
+}
+
+// prune drops the issues that haven't been checked for too long
+func (cache *IssueCache) prune() {
+	retention := cacheRetentionTTLs * cache.TTL
+	if retention < minCacheRetention {
+		retention = minCacheRetention
+	}
+
+	for key, cached := range cache.Issues {
+		if time.Since(cached.Checked) > retention {
+			delete(cache.Issues, key)
+		}
+	}
+}
+
+// save writes the cache if anything has changed, dropping the issues
+// that haven't been checked for too long
+func (cache *IssueCache) save() error {
+	if cache == nil || !cache.dirty {
+		return nil
+	}
+
+	cache.prune()
+
+	content, err := json.Marshal(cache.Issues)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(cache.path, content); err != nil {
+		return err
+	}
+
+	cache.dirty = false
+
+	return nil
+}
+
+func issueCacheKey(creds IssueAPI, repo string, id string) string {
+	return strings.Join([]string{creds.getHost(), repo, id}, "/")
+}
+
+// getIssue returns the cached issue while it's fresh, otherwise asks
+// the tracker whether it has changed since. A nil cache always asks
+// the tracker.
+func (cache *IssueCache) getIssue(ctx context.Context, creds IssueAPI, repo string, todo Todo) (Issue, error) {
+	// The trackers that live in the repo are fast and change under
+	// snitch's feet with `snitch close`
+	if _, local := creds.(IssueCloser); cache == nil || local {
+		return creds.getIssue(ctx, repo, todo)
+	}
+
+	key := issueCacheKey(creds, repo, *todo.ID)
+	cached, ok := cache.Issues[key]
+	if ok && cache.fresh(cached) {
+		return cached.Issue, nil
+	}
+
+	conditional := &conditionalRequest{}
+	if ok {
+		conditional.ETag = cached.ETag
+		conditional.LastModified = cached.LastModified
+	}
+
+	issue, err := creds.getIssue(withConditionalRequest(ctx, conditional), repo, todo)
+	if errors.Is(err, errNotModified) {
+		issue = cached.Issue
+	} else if err != nil {
+		return issue, err
+	}
+
+	cache.Issues[key] = CachedIssue{
+		Issue:        issue,
+		ETag:         conditional.ETag,
+		LastModified: conditional.LastModified,
+		Checked:      time.Now(),
+	}
+	cache.dirty = true
+
+	return issue, nil
+}
+
+// getIssues gets the issues of the todos keyed by their IDs, taking
+// the fresh ones from the cache and asking the tracker for the rest.
+// The batched responses aren't conditional, so the issues fetched in
+// batches are cached without the validators and are fetched in full
+// again once stale. Without the batches the stale issues are
+// revalidated one by one.
+func (cache *IssueCache) getIssues(ctx context.Context, creds IssueAPI, repo string, todos []Todo) (map[string]Issue, error) {
+	if _, local := creds.(IssueCloser); cache == nil || local {
+		return getIssues(ctx, creds, repo, todos)
+	}
+
+	issues := map[string]Issue{}
+	stale := []Todo{}
+	for _, todo := range todos {
+		cached, ok := cache.Issues[issueCacheKey(creds, repo, *todo.ID)]
+		if ok && cache.fresh(cached) {
+			issues[*todo.ID] = cached.Issue
+		} else {
+			stale = append(stale, todo)
+		}
+	}
+
+	if _, batcher := creds.(IssueBatcher); batcher && len(stale) > 1 {
+		fetched, err := getIssues(ctx, creds, repo, stale)
+		if err != nil {
+			return nil, err
+		}
+
+		// The validators of an older entry would describe an older
+		// state than the one being cached
+		for id, issue := range fetched {
+			issues[id] = issue
+			cache.Issues[issueCacheKey(creds, repo, id)] = CachedIssue{
+				Issue:   issue,
+				Checked: time.Now(),
+			}
+			cache.dirty = true
+		}
+
+		return issues, nil
+	}
+
+	for _, todo := range stale {
+		if _, ok := issues[*todo.ID]; ok {
+			continue
+		}
+
+		issue, err := cache.getIssue(ctx, creds, repo, todo)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		issues[*todo.ID] = issue
+	}
+
+	return issues, nil
+}
+
+// getIssueCache applies --cache-ttl <duration> and --no-cache. Returns
+// nil if the cache is disabled.
+func getIssueCache(params map[string]string) (*IssueCache, error) {
+	if _, noCache := params["no-cache"]; noCache {
+		return nil, nil
+	}
+
+	ttl := defaultCacheTTL
+	if value, ok := params["cache-ttl"]; ok {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("--cache-ttl expects a duration like 30s or 1h, got `%s'", value)
+		}
+	}
+
+	cachePath, err := issueCachePath()
+	if err != nil {
+		// No place for the cache, but snitch works without it
+		return nil, nil
+	}
+
+	return loadIssueCache(cachePath, ttl), nil
+}
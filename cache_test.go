@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIssueCache_ConditionalRequests(t *testing.T) {
+	state, etag := "open", `"v1"`
+	requests, notModified := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(`{"number": 42, "title": "Rewrite this in Rust", "state": "` + state + `"}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cachePath := filepath.Join(dir, "snitch", "issues.json")
+	creds := GiteaCredentials{Host: "gitea.example.com", Instance: Instance{BaseURL: server.URL}}
+	todo := Todo{ID: stringPtr("#42")}
+
+	retrieve := func(cache *IssueCache, wantState string, wantRequests int, wantNotModified int) {
+		t.Helper()
+
+		status, err := todo.RetrieveStatus(context.Background(), creds, "alice/snitch", cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if status != wantState || requests != wantRequests || notModified != wantNotModified {
+			t.Fatalf("got %s after %d requests (%d not modified), want %s after %d requests (%d not modified)",
+				status, requests, notModified, wantState, wantRequests, wantNotModified)
+		}
+	}
+
+	cache := loadIssueCache(cachePath, time.Hour)
+	retrieve(cache, "open", 1, 0)
+	// Fresh, not even asking
+	retrieve(cache, "open", 1, 0)
+
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stale, asking whether it has changed since
+	cache = loadIssueCache(cachePath, 0)
+	retrieve(cache, "open", 2, 1)
+
+	state, etag = "closed", `"v2"`
+	retrieve(cache, "closed", 3, 1)
+
+	if cache.Issues["gitea.example.com/alice/snitch/#42"].ETag != `"v2"` {
+		t.Errorf("the cache has %v", cache.Issues)
+	}
+
+	// No cache, no conditional requests
+	retrieve(nil, "closed", 4, 1)
+
+	// The closed issue is trusted while it's fresh unless its TODO is
+	// about to be removed
+	cache.TTL = time.Hour
+	retrieve(cache, "closed", 4, 1)
+
+	cache.RevalidateClosed = true
+	retrieve(cache, "closed", 5, 2)
+
+	state, etag = "open", `"v3"`
+	retrieve(cache, "open", 6, 2)
+	// The open ones are still fresh
+	retrieve(cache, "open", 6, 2)
+}
+
+func TestIssueCache_Prune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cachePath := filepath.Join(dir, "snitch", "issues.json")
+	cache := loadIssueCache(cachePath, time.Minute)
+	cache.Issues["gitea.example.com/alice/snitch/#1"] = CachedIssue{
+		Issue:   Issue{ID: "#1", State: issueOpen},
+		Checked: time.Now().Add(-time.Hour),
+	}
+	cache.Issues["gitea.example.com/alice/snitch/#2"] = CachedIssue{
+		Issue:   Issue{ID: "#2", State: issueOpen},
+		Checked: time.Now().Add(-2 * minCacheRetention),
+	}
+	cache.dirty = true
+
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache = loadIssueCache(cachePath, time.Minute)
+	if _, ok := cache.Issues["gitea.example.com/alice/snitch/#1"]; !ok || len(cache.Issues) != 1 {
+		t.Errorf("got %v, want only #1", cache.Issues)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ChangedLines maps file paths (relative to the current directory)
+// to the set of the line numbers added to them
+type ChangedLines map[string]map[int]bool
+
+var hunkHeaderRegexp = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Contains checks whether the line of the todo has been added
+func (changedLines ChangedLines) Contains(todo Todo) bool {
+	lines, ok := changedLines[strings.TrimPrefix(todo.Filename, "./")]
+	return ok && lines[todo.Line]
+}
+
+func parseDiffPath(line string) string {
+	line = strings.TrimSuffix(line, "\t")
+	if strings.HasPrefix(line, "\"") {
+		if unquoted, err := strconv.Unquote(line); err == nil {
+			line = unquoted
+		}
+	}
+
+	return strings.TrimPrefix(line, "b/")
+}
+
+// ParseDiff collects the added lines from a unified diff with zero
+// lines of context. The lines of the hunks are skipped by their counts,
+// so the changed lines that look like the headers aren't taken for
+// them.
+func ParseDiff(diff []byte) (ChangedLines, error) {
+	changedLines := ChangedLines{}
+	currentFile := ""
+	previousLine := ""
+	// hunkLines is how many lines of the current hunk are left
+	hunkLines := 0
+
+	reader := bufio.NewReader(bytes.NewReader(diff))
+	for {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hunkLines > 0 {
+			// `\ No newline at end of file` is not a line of the hunk
+			if !strings.HasPrefix(line, "\\") {
+				hunkLines--
+			}
+			continue
+		}
+
+		// The header of the new file always follows the old one
+		isNewFileHeader := strings.HasPrefix(line, "+++ ") && strings.HasPrefix(previousLine, "--- ")
+		previousLine = line
+
+		if isNewFileHeader {
+			currentFile = parseDiffPath(strings.TrimPrefix(line, "+++ "))
+			if currentFile == "/dev/null" {
+				currentFile = ""
+			}
+			continue
+		}
+
+		groups := hunkHeaderRegexp.FindStringSubmatch(line)
+		if groups == nil {
+			continue
+		}
+
+		removed, err := hunkCount(groups[1])
+		if err != nil {
+			return nil, err
+		}
+
+		start, err := strconv.Atoi(groups[2])
+		if err != nil {
+			return nil, err
+		}
+
+		count, err := hunkCount(groups[3])
+		if err != nil {
+			return nil, err
+		}
+
+		hunkLines = removed + count
+		if currentFile == "" {
+			continue
+		}
+
+		if changedLines[currentFile] == nil {
+			changedLines[currentFile] = map[int]bool{}
+		}
+
+		for lineNumber := start; lineNumber < start+count; lineNumber++ {
+			changedLines[currentFile][lineNumber] = true
+		}
+	}
+
+	return changedLines, nil
+}
+
+// hunkCount parses the line count of the hunk header, which is omitted
+// when it's 1
+func hunkCount(count string) (int, error) {
+	if len(count) == 0 {
+		return 1, nil
+	}
+
+	return strconv.Atoi(count)
+}
+
+// GitChangedLines collects the lines of the working tree added
+// since ref or, when staged is true, the lines added to the index
+func GitChangedLines(ref string, staged bool) (ChangedLines, error) {
+	args := []string{
+		"diff", "--relative", "--unified=0", "--no-color", "--no-ext-diff",
+		"--src-prefix=a/", "--dst-prefix=b/",
+	}
+
+	if staged {
+		args = append(args, "--cached")
+	}
+
+	if len(ref) > 0 {
+		args = append(args, ref)
+	}
+
+	cmd := exec.Command("git", args...)
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git diff failed: %s", strings.TrimSpace(errb.String()))
+	}
+
+	return ParseDiff(outb.Bytes())
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 3b18e51..a4c5a31 100644
+--- a/main.go
++++ b/main.go
+@@ -1,0 +2,2 @@ package main
++// TODO: first
++// TODO: second
+@@ -10 +12 @@ func main() {
+-	fmt.Println("Hello")
++	fmt.Println("World")
+@@ -20,3 +21,0 @@ func foo() {
+-	a()
+-	b()
+-	c()
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-// TODO: gone
+`
+
+	changedLines, err := ParseDiff([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filename string
+		line     int
+		out      bool
+	}{
+		{"main.go", 1, false},
+		{"main.go", 2, true},
+		{"./main.go", 3, true},
+		{"main.go", 4, false},
+		{"main.go", 12, true},
+		{"main.go", 21, false},
+		{"old.go", 1, false},
+	}
+
+	for _, tt := range tests {
+		todo := Todo{Filename: tt.filename, Line: tt.line}
+		if got := changedLines.Contains(todo); got != tt.out {
+			t.Errorf("%s:%d: got %t, want %t", tt.filename, tt.line, got, tt.out)
+		}
+	}
+}
+
+func TestParseDiff_LinesLikeHeaders(t *testing.T) {
+	// A minified line longer than the default bufio.Scanner buffer
+	// and the changed lines that look like the file headers
+	diff := "diff --git a/app.min.js b/app.min.js\n" +
+		"--- a/app.min.js\n" +
+		"+++ b/app.min.js\n" +
+		"@@ -1 +1 @@\n" +
+		"-var a=1;\n" +
+		"+" + strings.Repeat("var a=1;", 20000) + "\n" +
+		"diff --git a/notes.md b/notes.md\n" +
+		"--- a/notes.md\n" +
+		"+++ b/notes.md\n" +
+		"@@ -3 +3,2 @@\n" +
+		"--- old note\n" +
+		"+++ new note\n" +
+		"+// TODO: after the note\n" +
+		"\\ No newline at end of file\n" +
+		"@@ -10,0 +11 @@\n" +
+		"+// TODO: later\n"
+
+	changedLines, err := ParseDiff([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, todo := range []Todo{{Filename: "app.min.js", Line: 1}, {Filename: "notes.md", Line: 4}, {Filename: "notes.md", Line: 11}} {
+		if !changedLines.Contains(todo) {
+			t.Errorf("%s:%d is not found in %v", todo.Filename, todo.Line, changedLines)
+		}
+	}
+
+	if _, ok := changedLines["new note"]; ok {
+		t.Errorf("the changed line is taken for the file header: %v", changedLines)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileSnapshot remembers the state of a file at the moment it was
+// scanned for TODOs so we don't clobber changes made by somebody
+// else (usually an editor) before we update the file.
+type fileSnapshot struct {
+	Size int64
+	Hash [sha256.Size]byte
+}
+
+func newFileSnapshot(info os.FileInfo, content []byte) *fileSnapshot {
+	snapshot := &fileSnapshot{}
+	snapshot.update(info, content)
+	return snapshot
+}
+
+func (snapshot *fileSnapshot) update(info os.FileInfo, content []byte) {
+	if snapshot == nil {
+		return
+	}
+
+	snapshot.Size = info.Size()
+	snapshot.Hash = sha256.Sum256(content)
+}
+
+// matches reports whether the file still looks the same as when the
+// snapshot was taken. A nil snapshot matches anything.
+func (snapshot *fileSnapshot) matches(info os.FileInfo, content []byte) bool {
+	if snapshot == nil {
+		return true
+	}
+
+	if snapshot.Size != info.Size() {
+		return false
+	}
+
+	hash := sha256.Sum256(content)
+	return bytes.Equal(snapshot.Hash[:], hash[:])
+}
+
+// syncDir flushes the directory entry of a freshly renamed file to
+// the disk. Not every platform supports that, so errors are ignored.
+func syncDir(dirpath string) {
+	dir, err := os.Open(dirpath)
+	if err != nil {
+		return
+	}
+	defer dir.Close()
+
+	dir.Sync()
+}
+
+// writeFileAtomic replaces the file with the content through a
+// temporary file in the same directory, so the readers never see it
+// half-written
+func writeFileAtomic(filePath string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filePath), filepath.Base(filePath))
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(content)
+	if cerr := tmpFile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+	}
+
+	return err
+}
+
+// readLine reads a whole line no matter how long it is, without the
+// line terminator. Both the TODO walker and the file updater must
+// split lines the same way, otherwise their line numbers disagree.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		// The last line of the file without the trailing newline
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, nil
+}
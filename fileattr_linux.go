@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// copyFileAttributes copies the ownership and the extended
+// attributes of the file at srcPath to dst.
+func copyFileAttributes(srcPath string, dst *os.File, srcInfo os.FileInfo) error {
+	if stat, ok := srcInfo.Sys().(*syscall.Stat_t); ok {
+		// Changing the ownership requires privileges we usually
+		// don't have, but then the owner is most likely us anyway.
+		if err := dst.Chown(int(stat.Uid), int(stat.Gid)); err != nil && !os.IsPermission(err) {
+			return err
+		}
+	}
+
+	size, err := syscall.Listxattr(srcPath, nil)
+	if err != nil || size == 0 {
+		// The filesystem doesn't support xattrs or there are none
+		return nil
+	}
+
+	names := make([]byte, size)
+	size, err = syscall.Listxattr(srcPath, names)
+	if err != nil {
+		return nil
+	}
+
+	for _, name := range strings.Split(strings.TrimRight(string(names[:size]), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+
+		valueSize, err := syscall.Getxattr(srcPath, name, nil)
+		if err != nil {
+			continue
+		}
+
+		value := make([]byte, valueSize)
+		valueSize, err = syscall.Getxattr(srcPath, name, value)
+		if err != nil {
+			continue
+		}
+
+		// Some of the namespaces (like security.*) are off limits for
+		// regular users. Losing them is better than failing the update.
+		syscall.Setxattr(dst.Name(), name, value[:valueSize], 0)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func FuzzLineAsReportedTodo(f *testing.F) {
+	for _, line := range []string{
+		"// TODO(#42): rewrite this in Rust",
+		"# TODOOO(tsoding/ded#42): share this with ded",
+		"/* FIXME(https://gitlab.com/group/sub/project/-/issues/7): same as above",
+		"// TODO(#../../..): nothing to see here",
+		"// TODO(#1)(#2): (#3): ",
+	} {
+		f.Add(line)
+	}
+
+	project := Project{
+		Title:    &TitleConfig{},
+		Keywords: []string{"TODO", "FIXME"},
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		todo := project.lineAsReportedTodo(line)
+		if todo == nil {
+			return
+		}
+
+		// Updating the todo in the file must not change the line
+		if got := todo.String(); got != line {
+			t.Fatalf("%q is rewritten as %q", line, got)
+		}
+
+		ref, err := parseIssueRef(*todo.ID)
+		if err == nil && len(ref.Repo) == 0 && ref.ID() != *todo.ID {
+			t.Fatalf("%q is parsed as %q", *todo.ID, ref.ID())
+		}
+	})
+}
+
+func FuzzIssueAPIURL(f *testing.F) {
+	for _, id := range []string{
+		"#42",
+		"tsoding/ded#42",
+		"group/sub/project#7",
+		"https://github.com/tsoding/ded/issues/42",
+		"#../../user",
+		"../..#1",
+		"https://github.com/owner/../issues/1",
+	} {
+		f.Add(id)
+	}
+
+	github := GithubCredentials{Host: githubHost}
+	resolver := IssueResolver{
+		Creds:     github,
+		Repo:      "tsoding/snitch",
+		CrossRepo: true,
+		credentials: func() []IssueAPI {
+			return []IssueAPI{github}
+		},
+	}
+
+	builders := map[string]func(repo string, number int) string{
+		"github":    github.issueAPIURL,
+		"gitlab":    GitlabCredentials{Host: "gitlab.com"}.issueAPIURL,
+		"gitea":     GiteaCredentials{Host: "gitea.com"}.issueAPIURL,
+		"bitbucket": BitbucketCredentials{}.issueAPIURL,
+	}
+
+	f.Fuzz(func(t *testing.T, id string) {
+		_, repo, todo, err := resolver.resolve(Todo{ID: &id})
+		if err != nil {
+			return
+		}
+
+		number, err := issueNumber(todo)
+		if err != nil {
+			return
+		}
+
+		for name, builder := range builders {
+			rawURL := builder(repo, number)
+
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				t.Fatalf("%s: %q builds the broken URL %s: %s", name, id, rawURL, err)
+			}
+
+			if len(u.RawQuery) > 0 || len(u.Fragment) > 0 || path.Clean(u.Path) != u.Path {
+				t.Fatalf("%s: %q escapes the issue path with %s", name, id, rawURL)
+			}
+
+			if !strings.HasSuffix(u.Path, "/issues/"+strconv.Itoa(number)) {
+				t.Fatalf("%s: %q points to %s", name, id, rawURL)
+			}
+		}
+	})
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/ini.v1"
+)
+
+// GenericRequestConfig describes an HTTP request of the generic
+// tracker. URL, Body and the headers are text/template templates.
+type GenericRequestConfig struct {
+	Method string
+	URL    string
+	Body   string
+}
+
+// GenericCreateConfig describes how to create an issue. ID is the
+// path of the issue ID in the JSON response, like `data.number`.
+type GenericCreateConfig struct {
+	GenericRequestConfig `yaml:",inline"`
+	ID                   string `yaml:"id"`
+}
+
+// GenericGetConfig describes how to get an issue. State is the path
+// of the issue state in the JSON response, like `data.state`.
+type GenericGetConfig struct {
+	GenericRequestConfig `yaml:",inline"`
+	State                string
+}
+
+// GenericConfig contains the project level configuration of the
+// generic tracker for teams with in-house trackers
+type GenericConfig struct {
+	Project      string
+	Create       GenericCreateConfig
+	Get          GenericGetConfig
+	Headers      map[string]string
+	ClosedStates []string `yaml:"closed_states"`
+	// IDPrefix is prepended to the IDs in the code, `#` by default
+	IDPrefix *string `yaml:"id_prefix"`
+	// Link is the template of the web page of an issue
+	Link string
+}
+
+// GenericTracker talks to any JSON REST API described by GenericConfig
+type GenericTracker struct {
+	Config GenericConfig
+	// Tokens maps the hosts to the tokens available in the templates
+	// as {{.Token}}. A token is never sent to any other host.
+	Tokens map[string]string
+}
+
+type genericTemplateData struct {
+	Project  string
+	Title    string
+	Body     string
+	ID       string
+	Assignee string
+	Token    string
+}
+
+var genericTemplateFuncs = template.FuncMap{
+	// json makes a JSON literal out of a value, handy for the bodies
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func renderGenericTemplate(name, text string, data genericTemplateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(genericTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// lookupJSONPath follows a dot separated path like `data.items.0.id`
+// through the decoded JSON
+func lookupJSONPath(v interface{}, fieldPath string) (string, error) {
+	for _, field := range strings.Split(fieldPath, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[field]; !ok {
+				return "", fmt.Errorf("field `%s' is missing in the response", fieldPath)
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(field)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", fmt.Errorf("field `%s' is missing in the response", fieldPath)
+			}
+			v = node[index]
+		default:
+			return "", fmt.Errorf("field `%s' is missing in the response", fieldPath)
+		}
+	}
+
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		return "", fmt.Errorf("field `%s' is not a scalar", fieldPath)
+	}
+}
+
+func (tracker GenericTracker) idPrefix() string {
+	if tracker.Config.IDPrefix != nil {
+		return *tracker.Config.IDPrefix
+	}
+
+	return "#"
+}
+
+// query runs the request returning the decoded JSON. The layout of the
+// response is only known to the configured paths.
+func (tracker GenericTracker) query(ctx context.Context, request GenericRequestConfig, data genericTemplateData) (interface{}, error) {
+	requestURL, err := renderGenericTemplate("url", request.URL, data)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		return nil, err
+	}
+	data.Token = tracker.Tokens[parsedURL.Host]
+
+	body, err := renderGenericTemplate("body", request.Body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	method := request.Method
+	if len(method) == 0 {
+		method = "GET"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	for name, value := range tracker.Config.Headers {
+		value, err := renderGenericTemplate("header "+name, value, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, value)
+	}
+
+	var response interface{}
+	if err := QueryHTTP(req, &response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (tracker GenericTracker) getIssue(ctx context.Context, project string, todo Todo) (Issue, error) {
+	// The ID ends up in the URL template, so it must not be able to
+	// change the path
+	ref, err := parseIssueKey(*todo.ID, tracker.idPrefix(), nil)
+	if err != nil {
+		return Issue{}, fmt.Errorf("`%s' is not an issue ID of %s", *todo.ID, tracker.getHost())
+	}
+
+	response, err := tracker.query(ctx, tracker.Config.Get.GenericRequestConfig, genericTemplateData{
+		Project: project,
+		ID:      ref.Key,
+	})
+	if err != nil {
+		return Issue{}, err
+	}
+
+	state, err := lookupJSONPath(response, tracker.Config.Get.State)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	closed := false
+	for _, closedState := range tracker.Config.ClosedStates {
+		if strings.EqualFold(state, closedState) {
+			closed = true
+		}
+	}
+
+	return Issue{
+		ID:    *todo.ID,
+		State: issueState(closed),
+	}, nil
+}
+
+func (tracker GenericTracker) postIssue(ctx context.Context, project string, todo Todo, body string) (Issue, error) {
+	response, err := tracker.query(ctx, tracker.Config.Create.GenericRequestConfig, genericTemplateData{
+		Project:  project,
+		Title:    todo.Title,
+		Body:     body,
+		Assignee: todo.Assignee,
+	})
+	if err != nil {
+		return Issue{}, err
+	}
+
+	issueID, err := lookupJSONPath(response, tracker.Config.Create.ID)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return Issue{
+		ID:    tracker.idPrefix() + issueID,
+		Title: todo.Title,
+		State: issueOpen,
+	}, nil
+}
+
+func (tracker GenericTracker) getHost() string {
+	if parsedURL, err := url.Parse(tracker.Config.Create.URL); err == nil {
+		return parsedURL.Host
+	}
+
+	return tracker.Config.Create.URL
+}
+
+func (tracker GenericTracker) projectURL(project string) string {
+	return "https://" + tracker.getHost()
+}
+
+func (tracker GenericTracker) issueURL(project string, todo Todo) string {
+	link := tracker.Config.Link
+	if len(link) == 0 {
+		link = tracker.Config.Get.URL
+	}
+
+	issueURL, err := renderGenericTemplate("link", link, genericTemplateData{
+		Project: project,
+		ID:      strings.TrimPrefix(*todo.ID, tracker.idPrefix()),
+	})
+	if err != nil {
+		return *todo.ID
+	}
+
+	return issueURL
+}
+
+// GenericTokensFromFile gets the generic tracker tokens from a filepath
+func GenericTokensFromFile(filepath string, tokens map[string]string) {
+	cfg, err := ini.Load(filepath)
+	if err != nil {
+		return
+	}
+
+	for _, section := range cfg.Sections()[1:] {
+		if _, ok := tokens[section.Name()]; !ok {
+			tokens[section.Name()] = section.Key("token").String()
+		}
+	}
+}
+
+func getGenericTokens() map[string]string {
+	tokens := map[string]string{}
+	tokenEnvar := os.Getenv("GENERIC_TRACKER_TOKEN")
+	xdgEnvar := os.Getenv("XDG_CONFIG_HOME")
+	usr, err := user.Current()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(tokenEnvar) != 0 {
+		for _, credential := range strings.Split(tokenEnvar, ",") {
+			separator := strings.LastIndex(credential, ":")
+			if separator < 0 {
+				fmt.Fprintf(os.Stderr, "Couldn't parse generic tracker credentials from ENV: %s\n", credential)
+				continue
+			}
+			tokens[credential[:separator]] = credential[separator+1:]
+		}
+	}
+
+	// custom XDG_CONFIG_HOME
+	if len(xdgEnvar) != 0 {
+		filePath := path.Join(xdgEnvar, "snitch/generic.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			GenericTokensFromFile(filePath, tokens)
+		}
+	}
+
+	// default XDG_CONFIG_HOME
+	if len(xdgEnvar) == 0 {
+		filePath := path.Join(usr.HomeDir, ".config/snitch/generic.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			GenericTokensFromFile(filePath, tokens)
+		}
+	}
+
+	filePath := path.Join(usr.HomeDir, ".snitch/generic.ini")
+	if _, err := os.Stat(filePath); err == nil {
+		GenericTokensFromFile(filePath, tokens)
+	}
+
+	return tokens
+}
+
+func getGenericTracker(config *GenericConfig) (string, IssueAPI, error) {
+	if config == nil || len(config.Create.URL) == 0 || len(config.Get.URL) == 0 {
+		return "", nil, fmt.Errorf("Generic tracker requires `generic.create.url' and `generic.get.url' in .snitch.yaml")
+	}
+
+	if len(config.Create.ID) == 0 || len(config.Get.State) == 0 {
+		return "", nil, fmt.Errorf("Generic tracker requires `generic.create.id' and `generic.get.state' in .snitch.yaml")
+	}
+
+	return config.Project, GenericTracker{
+		Config: *config,
+		Tokens: getGenericTokens(),
+	}, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestGenericTracker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/snitch/tickets":
+			var ticket map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&ticket); err != nil || ticket["summary"] != `Rewrite "this" in Rust` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"result": {"tickets": [{"key": "T-17"}]}}`))
+		case r.Method == "GET" && r.URL.Path == "/api/tickets/T-17":
+			w.Write([]byte(`{"result": {"phase": "Shipped"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := GenericConfig{}
+	err := yaml.Unmarshal([]byte(strings.Replace(`
+project: snitch
+create:
+  method: POST
+  url: SERVER/api/{{.Project}}/tickets
+  body: '{"summary": {{json .Title}}, "details": {{json .Body}}}'
+  id: result.tickets.0.key
+get:
+  url: SERVER/api/tickets/{{.ID}}
+  state: result.phase
+headers:
+  Authorization: Bearer {{.Token}}
+closed_states: [shipped, rejected]
+id_prefix: ""
+`, "SERVER", server.URL, -1)), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := GenericTracker{
+		Config: config,
+		Tokens: map[string]string{serverURL.Host: "secret"},
+	}
+
+	todo, err := Todo{Title: `Rewrite "this" in Rust`}.Report(context.Background(), tracker, "snitch", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := derefString(todo.ID); got != "T-17" {
+		t.Errorf("got ID %q, want %q", got, "T-17")
+	}
+
+	status, err := todo.RetrieveStatus(context.Background(), tracker, "snitch", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != "closed" {
+		t.Errorf("got status %q, want %q", status, "closed")
+	}
+
+	// The token of the tracker must not leak to the other hosts
+	tracker.Tokens = map[string]string{"tracker.example.com": "secret"}
+	if _, err := todo.RetrieveStatus(context.Background(), tracker, "snitch", nil); err == nil {
+		t.Errorf("expected the request without the token to fail")
+	}
+}
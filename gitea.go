@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/user"
 	"path"
@@ -14,63 +17,318 @@ import (
 	"gopkg.in/ini.v1"
 )
 
+// Flavors of the Gitea API family
+const (
+	giteaFlavor   = "gitea"
+	forgejoFlavor = "forgejo"
+	gogsFlavor    = "gogs"
+)
+
 // GiteaCredentials contains PersonalToken for gitea API authorization
-// and Host for possibly implementing support for self-hosted instances
+// and Host and Instance for self-hosted instances. The same API is
+// served by Forgejo and Gogs with minor differences, so Flavor is
+// either gitea, forgejo, gogs or empty for autodetection.
 type GiteaCredentials struct {
 	Host          string
 	PersonalToken string
+	Flavor        string
+	Instance
+}
+
+func (creds GiteaCredentials) apiURL() string {
+	return creds.baseURL(creds.Host) + "/api/v1"
 }
 
-func (creds GiteaCredentials) query(method, url string, jsonBody map[string]interface{}) (map[string]interface{}, error) {
+// detectFlavor asks the instance what it is unless Flavor is set
+// explicitly. Gogs doesn't have the version endpoint at all, Forgejo
+// reports the version of Gitea it's compatible with after `+gitea-`.
+func (creds GiteaCredentials) detectFlavor(ctx context.Context) (string, error) {
+	switch creds.Flavor {
+	case giteaFlavor, forgejoFlavor, gogsFlavor:
+		return creds.Flavor, nil
+	case "":
+	default:
+		return "", fmt.Errorf("Unknown Gitea flavor `%s'. Expected gitea, forgejo or gogs", creds.Flavor)
+	}
+
+	version := struct {
+		Version string `json:"version"`
+	}{}
+	err := creds.query(ctx, "GET", creds.apiURL()+"/version", nil, &version)
+	var apiErr *APIError
+	if errors.Is(err, ErrNotFound) || (errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusMethodNotAllowed) {
+		// Gogs doesn't have the endpoint, falling back to the
+		// most conservative API
+		return gogsFlavor, nil
+	}
+	if err != nil {
+		// Wrong token, timeouts and such are not a sign of Gogs
+		return "", err
+	}
+
+	if strings.Contains(version.Version, "+gitea-") || strings.Contains(strings.ToLower(version.Version), "forgejo") {
+		return forgejoFlavor, nil
+	}
+
+	return giteaFlavor, nil
+}
+
+// giteaIssue is the part of a Gitea issue snitch cares about. Gogs
+// and the old Gitea versions may call the number index. The users and
+// the labels look the same as on GitHub.
+type giteaIssue struct {
+	Number *int   `json:"number"`
+	Index  *int   `json:"index"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	// Gogs only has the single Assignee
+	Assignee  *githubUser   `json:"assignee"`
+	Assignees []githubUser  `json:"assignees"`
+	Labels    []githubLabel `json:"labels"`
+}
+
+func (issue giteaIssue) normalize() (Issue, error) {
+	number := issue.Number
+	if number == nil {
+		number = issue.Index
+	}
+
+	if number == nil {
+		return Issue{}, errMissingField("Gitea", "number")
+	}
+
+	if len(issue.State) == 0 {
+		return Issue{}, errMissingField("Gitea", "state")
+	}
+
+	assignees := issue.Assignees
+	if len(assignees) == 0 && issue.Assignee != nil {
+		assignees = []githubUser{*issue.Assignee}
+	}
+
+	return Issue{
+		ID:        "#" + strconv.Itoa(*number),
+		Title:     issue.Title,
+		State:     issueState(strings.EqualFold(issue.State, issueClosed)),
+		Assignees: githubLogins(assignees),
+		Labels:    githubLabelNames(issue.Labels),
+	}, nil
+}
+
+func (creds GiteaCredentials) query(ctx context.Context, method, url string, jsonBody map[string]interface{}, v interface{}) error {
 	bodyBuffer := new(bytes.Buffer)
 	err := json.NewEncoder(bodyBuffer).Encode(jsonBody)
 
-	req, err := http.NewRequest(method, url, bodyBuffer)
+	client, err := creds.client()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyBuffer)
+	if err != nil {
+		return err
 	}
 	req.Header.Add("Authorization", "token "+creds.PersonalToken)
 	req.Header.Add("Content-Type", "application/json")
 
-	return QueryHTTP(req)
+	return QueryHTTPWithClient(client, req, v)
 }
 
-func (creds GiteaCredentials) getIssue(repo string, todo Todo) (map[string]interface{}, error) {
-	// FIXME(#187): gitea integration does not support http instances.
-	json, err := creds.query(
-		"GET",
-		"https://"+creds.Host+"/api/v1/repos/"+repo+"/issues/"+(*todo.ID)[1:],
-		nil) // self-hosted
+// issueAPIURL is the URL of the issue in the API
+func (creds GiteaCredentials) issueAPIURL(repo string, number int) string {
+	return creds.apiURL() + "/repos/" + repo + "/issues/" + strconv.Itoa(number)
+}
 
+func (creds GiteaCredentials) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	number, err := issueNumber(todo)
 	if err != nil {
-		return nil, err
+		return Issue{}, err
+	}
+
+	issue := giteaIssue{}
+	err = creds.query(ctx, "GET", creds.issueAPIURL(repo, number), nil, &issue)
+	if err != nil {
+		return Issue{}, err
 	}
 
-	return json, nil
+	return issue.normalize()
 }
 
-func (creds GiteaCredentials) postIssue(repo string, todo Todo, body string) (Todo, error) {
-	json, err := creds.query(
+func (creds GiteaCredentials) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	params := map[string]interface{}{
+		"title": todo.Title,
+		"body":  body,
+	}
+	if len(todo.Assignee) > 0 {
+		flavor, err := creds.detectFlavor(ctx)
+		if err != nil {
+			return Issue{}, err
+		}
+
+		// Gogs only supports a single assignee
+		if flavor == gogsFlavor {
+			params["assignee"] = todo.Assignee
+		} else {
+			params["assignees"] = []string{todo.Assignee}
+		}
+	}
+
+	issue := giteaIssue{}
+	err := creds.query(ctx,
 		"POST",
-		"https://"+creds.Host+"/api/v1/repos/"+repo+"/issues",
-		map[string]interface{}{
-			"title": todo.Title,
-			"body":  body,
-		}) // self-hosted
+		creds.apiURL()+"/repos/"+repo+"/issues",
+		params,
+		&issue)
 	if err != nil {
-		return todo, err
+		return Issue{}, err
+	}
+
+	return issue.normalize()
+}
+
+// giteaPageSize is the default maximum page size of Gitea
+const giteaPageSize = 50
+
+// getIssues lists the issues of the repo newest first until all of the
+// asked ones are found. Gitea can't filter the list by the numbers,
+// but the pages stop as soon as they get older than the oldest asked
+// issue. Gogs can't list the closed and the open issues together, so
+// it's asked one by one, and so are the numbers too sparse to fit in
+// fewer pages than there are issues.
+func (creds GiteaCredentials) getIssues(ctx context.Context, repo string, todos []Todo) ([]Issue, error) {
+	flavor, err := creds.detectFlavor(ctx)
+	if err != nil || flavor == gogsFlavor {
+		return nil, err
 	}
 
-	id := "#" + strconv.Itoa(int(json["number"].(float64)))
-	todo.ID = &id
+	numbers := issueNumbers(todos)
+	if len(numbers) == 0 {
+		return nil, nil
+	}
 
-	return todo, err
+	wanted := map[int]bool{}
+	oldest, newest := numbers[0], numbers[0]
+	for _, number := range numbers {
+		wanted[number] = true
+		if number < oldest {
+			oldest = number
+		}
+		if number > newest {
+			newest = number
+		}
+	}
+
+	// The pages go down to the oldest asked issue no matter how sparse the
+	// numbers are, asking one by one is cheaper then
+	if (newest-oldest)/giteaPageSize+1 >= len(numbers) {
+		return nil, nil
+	}
+
+	issues := []Issue{}
+	for page := 1; len(wanted) > 0; page++ {
+		params := url.Values{}
+		params.Add("state", "all")
+		params.Add("type", "issues")
+		params.Add("limit", strconv.Itoa(giteaPageSize))
+		params.Add("page", strconv.Itoa(page))
+
+		list := []giteaIssue{}
+		err := creds.query(ctx, "GET", creds.apiURL()+"/repos/"+repo+"/issues?"+params.Encode(), nil, &list)
+		if err != nil {
+			return nil, err
+		}
+
+		reachedOldest := false
+		for _, issue := range list {
+			normalized, err := issue.normalize()
+			if err != nil {
+				return nil, err
+			}
+
+			number, _ := strconv.Atoi(strings.TrimPrefix(normalized.ID, "#"))
+			if wanted[number] {
+				delete(wanted, number)
+				issues = append(issues, normalized)
+			}
+			if number <= oldest {
+				reachedOldest = true
+			}
+		}
+
+		if len(list) < giteaPageSize || reachedOldest {
+			break
+		}
+	}
+
+	return issues, nil
+}
+
+func (creds GiteaCredentials) getIssueBody(ctx context.Context, repo string, todo Todo) (string, error) {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return "", err
+	}
+
+	issue := struct {
+		Body string `json:"body"`
+	}{}
+	err = creds.query(ctx, "GET", creds.issueAPIURL(repo, number), nil, &issue)
+	return issue.Body, err
+}
+
+func (creds GiteaCredentials) editIssueBody(ctx context.Context, repo string, todo Todo, body string) error {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return err
+	}
+
+	issue := giteaIssue{}
+	return creds.query(ctx, "PATCH", creds.issueAPIURL(repo, number), map[string]interface{}{
+		"body": body,
+	}, &issue)
+}
+
+func (creds GiteaCredentials) commentIssue(ctx context.Context, repo string, todo Todo, comment string) error {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return err
+	}
+
+	response := struct{}{}
+	return creds.query(ctx, "POST", creds.issueAPIURL(repo, number)+"/comments", map[string]interface{}{
+		"body": comment,
+	}, &response)
+}
+
+// permalink links to the file at the commit. Gogs doesn't have the
+// commit/ part in its links.
+func (creds GiteaCredentials) permalink(repo string, commit string, filename string, line int) string {
+	src := "/src/commit/"
+	if creds.Flavor == gogsFlavor {
+		src = "/src/"
+	}
+
+	return creds.baseURL(creds.Host) + "/" + repo + src + commit + "/" + escapeFilename(filename) + "#L" + strconv.Itoa(line)
 }
 
 func (creds GiteaCredentials) getHost() string {
 	return creds.Host
 }
 
+// matchRemote extracts <owner>/<repo> from the remote URL of the
+// instance, which may be behind a reverse proxy with a path prefix
+func (creds GiteaCredentials) matchRemote(remoteURL string) (string, bool) {
+	return matchHostRepo(creds.Host, creds.stripPathPrefix(remoteURL), `[-.\w]+/[-.\w]+`)
+}
+
+func (creds GiteaCredentials) projectURL(repo string) string {
+	return creds.baseURL(creds.Host) + "/" + repo
+}
+
+func (creds GiteaCredentials) issueURL(repo string, todo Todo) string {
+	return creds.baseURL(creds.Host) + "/" + repo + "/issues/" + strings.TrimPrefix(*todo.ID, "#")
+}
+
 // GiteaCredentialsFromFile gets GiteaCredentials from a filepath
 func GiteaCredentialsFromFile(filepath string) []GiteaCredentials {
 	credentials := []GiteaCredentials{}
@@ -84,6 +342,8 @@ func GiteaCredentialsFromFile(filepath string) []GiteaCredentials {
 		credentials = append(credentials, GiteaCredentials{
 			Host:          section.Name(),
 			PersonalToken: section.Key("access_token").String(),
+			Flavor:        section.Key("flavor").String(),
+			Instance:      instanceFromSection(section),
 		})
 	}
 
@@ -92,6 +352,14 @@ func GiteaCredentialsFromFile(filepath string) []GiteaCredentials {
 
 // GiteaCredentialsFromToken returns a GiteaCredentials from a string token
 func GiteaCredentialsFromToken(token string) (GiteaCredentials, error) {
+	if instance, host, personalToken, ok := instanceFromToken(token); ok {
+		return GiteaCredentials{
+			Host:          host,
+			PersonalToken: personalToken,
+			Instance:      instance,
+		}, nil
+	}
+
 	credentials := strings.Split(token, ":")
 
 	switch len(credentials) {
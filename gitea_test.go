@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// newGiteaFixtureServer replays the API responses recorded from the
+// instance of the flavor. Missing fixtures are served as 404, like the
+// version endpoint of Gogs. The requests of the other endpoints fail
+// the test.
+func newGiteaFixtureServer(t *testing.T, flavor string, assigneeFields *[]string) *httptest.Server {
+	t.Helper()
+
+	fixtures := map[string]string{
+		"GET /api/v1/version":                      "version.json",
+		"POST /api/v1/repos/alice/snitch/issues":   "create_issue.json",
+		"GET /api/v1/repos/alice/snitch/issues/12": "get_issue.json",
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method == "POST" {
+			issue := map[string]interface{}{}
+			if err := json.NewDecoder(r.Body).Decode(&issue); err != nil {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			for _, field := range []string{"assignee", "assignees"} {
+				if _, ok := issue[field]; ok {
+					*assigneeFields = append(*assigneeFields, field)
+				}
+			}
+		}
+
+		fixture, ok := fixtures[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join("testdata", "gitea", flavor, fixture))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	}))
+}
+
+func TestGiteaCredentials_Flavors(t *testing.T) {
+	tests := []struct {
+		flavor        string
+		explicit      string
+		assigneeField string
+	}{
+		{giteaFlavor, "", "assignees"},
+		{forgejoFlavor, "", "assignees"},
+		{gogsFlavor, "", "assignee"},
+		{gogsFlavor, gogsFlavor, "assignee"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flavor+"/"+tt.explicit, func(t *testing.T) {
+			assigneeFields := []string{}
+			server := newGiteaFixtureServer(t, tt.flavor, &assigneeFields)
+			defer server.Close()
+
+			creds := GiteaCredentials{
+				Host:          "gitea.example.com",
+				PersonalToken: "secret",
+				Flavor:        tt.explicit,
+				Instance:      Instance{BaseURL: server.URL},
+			}
+
+			if tt.explicit == "" {
+				flavor, err := creds.detectFlavor(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if flavor != tt.flavor {
+					t.Errorf("got flavor %q, want %q", flavor, tt.flavor)
+				}
+			}
+
+			todo, err := Todo{Title: "Rewrite this in Rust", Assignee: "bob"}.Report(context.Background(), creds, "alice/snitch", "No really.")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := derefString(todo.ID); got != "#12" {
+				t.Errorf("got ID %q, want %q", got, "#12")
+			}
+
+			if len(assigneeFields) != 1 || assigneeFields[0] != tt.assigneeField {
+				t.Errorf("got assignee fields %v, want [%s]", assigneeFields, tt.assigneeField)
+			}
+
+			status, err := todo.RetrieveStatus(context.Background(), creds, "alice/snitch", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if status != "closed" {
+				t.Errorf("got status %q, want %q", status, "closed")
+			}
+		})
+	}
+}
+
+func TestGiteaCredentials_DetectFlavorErrors(t *testing.T) {
+	server := newGiteaFixtureServer(t, giteaFlavor, &[]string{})
+	defer server.Close()
+
+	// A wrong token is not a sign of Gogs
+	creds := GiteaCredentials{
+		Host:          "gitea.example.com",
+		PersonalToken: "wrong",
+		Instance:      Instance{BaseURL: server.URL},
+	}
+	if flavor, err := creds.detectFlavor(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got %q %v, want %v", flavor, err, ErrUnauthorized)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	creds.PersonalToken = "secret"
+	if flavor, err := creds.detectFlavor(ctx); err == nil {
+		t.Errorf("got %q for the cancelled context", flavor)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"gopkg.in/ini.v1"
@@ -10,84 +11,356 @@ import (
 	"os/user"
 	"path"
 	"strconv"
+	"strings"
 )
 
+const githubHost = "github.com"
+
 // GithubCredentials contains PersonalToken for GitHub API authorization
+// and Host for GitHub Enterprise Server instances
 type GithubCredentials struct {
+	Host          string
 	PersonalToken string
+
+	// restURL is only overridden in tests
+	restURL string
+}
+
+func (creds GithubCredentials) apiURL() string {
+	if len(creds.restURL) > 0 {
+		return creds.restURL
+	}
+
+	if creds.Host == githubHost {
+		return "https://api.github.com"
+	}
+
+	// GitHub Enterprise Server
+	return "https://" + creds.Host + "/api/v3"
+}
+
+// githubIssue is the part of a GitHub issue snitch cares about
+type githubIssue struct {
+	Number    *int          `json:"number"`
+	Title     string        `json:"title"`
+	State     string        `json:"state"`
+	Assignees []githubUser  `json:"assignees"`
+	Labels    []githubLabel `json:"labels"`
+}
+
+type githubUser struct {
+	Login string `json:"login"`
 }
 
-func (creds GithubCredentials) query(method, url string, jsonBody map[string]interface{}) (map[string]interface{}, error) {
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+// githubGraphQLIssue is githubIssue the way GraphQL returns it, with
+// the connections in place of the lists
+type githubGraphQLIssue struct {
+	Number    *int   `json:"number"`
+	Title     string `json:"title"`
+	State     string `json:"state"`
+	Assignees struct {
+		Nodes []githubUser `json:"nodes"`
+	} `json:"assignees"`
+	Labels struct {
+		Nodes []githubLabel `json:"nodes"`
+	} `json:"labels"`
+}
+
+func (issue githubGraphQLIssue) normalize() (Issue, error) {
+	return githubIssue{
+		Number:    issue.Number,
+		Title:     issue.Title,
+		State:     issue.State,
+		Assignees: issue.Assignees.Nodes,
+		Labels:    issue.Labels.Nodes,
+	}.normalize()
+}
+
+func (issue githubIssue) normalize() (Issue, error) {
+	if issue.Number == nil {
+		return Issue{}, errMissingField("GitHub", "number")
+	}
+
+	if len(issue.State) == 0 {
+		return Issue{}, errMissingField("GitHub", "state")
+	}
+
+	return Issue{
+		ID:    "#" + strconv.Itoa(*issue.Number),
+		Title: issue.Title,
+		// GraphQL says OPEN, CLOSED and MERGED for the pull requests
+		State:     issueState(!strings.EqualFold(issue.State, issueOpen)),
+		Assignees: githubLogins(issue.Assignees),
+		Labels:    githubLabelNames(issue.Labels),
+	}, nil
+}
+
+func githubLogins(users []githubUser) []string {
+	var logins []string
+	for _, user := range users {
+		logins = append(logins, user.Login)
+	}
+
+	return logins
+}
+
+func githubLabelNames(labels []githubLabel) []string {
+	var names []string
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+
+	return names
+}
+
+func (creds GithubCredentials) query(ctx context.Context, method, url string, jsonBody map[string]interface{}, v interface{}) error {
 	bodyBuffer := new(bytes.Buffer)
 	err := json.NewEncoder(bodyBuffer).Encode(jsonBody)
 
-	req, err := http.NewRequest(method, url, bodyBuffer)
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyBuffer)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	req.Header.Add("Authorization", "token "+creds.PersonalToken)
 	req.Header.Add("Content-Type", "application/json")
 
-	return QueryHTTP(req)
+	return QueryHTTP(req, v)
 }
 
-func (creds GithubCredentials) getIssue(repo string, todo Todo) (map[string]interface{}, error) {
-	json, err := creds.query(
-		"GET",
-		// FIXME(#59): possible GitHub API injection attack
-		"https://api.github.com/repos/"+repo+"/issues/"+(*todo.ID)[1:],
-		nil)
+// issueAPIURL is the URL of the issue in the REST API
+func (creds GithubCredentials) issueAPIURL(repo string, number int) string {
+	return creds.apiURL() + "/repos/" + repo + "/issues/" + strconv.Itoa(number)
+}
 
+func (creds GithubCredentials) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	number, err := issueNumber(todo)
 	if err != nil {
-		return nil, err
+		return Issue{}, err
 	}
 
-	return json, nil
+	issue := githubIssue{}
+	err = creds.query(ctx, "GET", creds.issueAPIURL(repo, number), nil, &issue)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return issue.normalize()
 }
 
-func (creds GithubCredentials) postIssue(repo string, todo Todo, body string) (Todo, error) {
-	json, err := creds.query(
+func (creds GithubCredentials) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	params := map[string]interface{}{
+		"title": todo.Title,
+		"body":  body,
+	}
+	if len(todo.Assignee) > 0 {
+		params["assignees"] = []string{todo.Assignee}
+	}
+
+	issue := githubIssue{}
+	err := creds.query(ctx,
 		"POST",
-		"https://api.github.com/repos/"+repo+"/issues",
-		map[string]interface{}{
-			"title": todo.Title,
-			"body":  body,
-		})
+		creds.apiURL()+"/repos/"+repo+"/issues",
+		params,
+		&issue)
 	if err != nil {
-		return todo, err
+		return Issue{}, err
 	}
 
-	id := "#" + strconv.Itoa(int(json["number"].(float64)))
-	todo.ID = &id
+	return issue.normalize()
+}
 
-	return todo, err
+// githubBatchSize is the number of issues asked in a single GraphQL
+// query, well below the node limit of the API
+const githubBatchSize = 100
+
+// githubGraphQLIssueFields are the fields of githubGraphQLIssue shared
+// by the issues and the pull requests
+const githubGraphQLIssueFields = "number title state " +
+	"assignees(first: 10) { nodes { login } } " +
+	"labels(first: 20) { nodes { name } }"
+
+func (creds GithubCredentials) graphqlURL() string {
+	if len(creds.restURL) > 0 {
+		return creds.restURL + "/graphql"
+	}
+
+	if creds.Host == githubHost {
+		return "https://api.github.com/graphql"
+	}
+
+	return "https://" + creds.Host + "/api/graphql"
+}
+
+// getIssues asks for up to githubBatchSize issues in one GraphQL query
+// with an alias per issue number
+func (creds GithubCredentials) getIssues(ctx context.Context, repo string, todos []Todo) ([]Issue, error) {
+	ownerAndName := strings.SplitN(repo, "/", 2)
+	if len(ownerAndName) != 2 {
+		return nil, fmt.Errorf("%s is not a GitHub repo", repo)
+	}
+
+	numbers := issueNumbers(todos)
+	issues := []Issue{}
+
+	for start := 0; start < len(numbers); start += githubBatchSize {
+		end := start + githubBatchSize
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+
+		var query strings.Builder
+		query.WriteString("query ($owner: String!, $name: String!) {\n  repository(owner: $owner, name: $name) {\n")
+		for _, number := range numbers[start:end] {
+			fmt.Fprintf(&query, "    i%d: issueOrPullRequest(number: %d) {\n"+
+				"      ... on Issue { %s }\n"+
+				"      ... on PullRequest { %s }\n"+
+				"    }\n", number, number, githubGraphQLIssueFields, githubGraphQLIssueFields)
+		}
+		query.WriteString("  }\n}")
+
+		response := struct {
+			Data struct {
+				Repository map[string]*githubGraphQLIssue `json:"repository"`
+			} `json:"data"`
+			Errors []struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}{}
+
+		err := creds.query(ctx, "POST", creds.graphqlURL(), map[string]interface{}{
+			"query": query.String(),
+			"variables": map[string]interface{}{
+				"owner": ownerAndName[0],
+				"name":  ownerAndName[1],
+			},
+		}, &response)
+		if err != nil {
+			return nil, err
+		}
+
+		// The missing issues are reported as NOT_FOUND errors along
+		// with the rest of the data
+		for _, e := range response.Errors {
+			if e.Type != "NOT_FOUND" || response.Data.Repository == nil {
+				return nil, fmt.Errorf("GitHub GraphQL error: %s", e.Message)
+			}
+		}
+
+		for _, issue := range response.Data.Repository {
+			if issue == nil {
+				continue
+			}
+
+			normalized, err := issue.normalize()
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, normalized)
+		}
+	}
+
+	return issues, nil
+}
+
+func (creds GithubCredentials) getIssueBody(ctx context.Context, repo string, todo Todo) (string, error) {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return "", err
+	}
+
+	issue := struct {
+		Body string `json:"body"`
+	}{}
+	err = creds.query(ctx, "GET", creds.issueAPIURL(repo, number), nil, &issue)
+	return issue.Body, err
+}
+
+func (creds GithubCredentials) editIssueBody(ctx context.Context, repo string, todo Todo, body string) error {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return err
+	}
+
+	issue := githubIssue{}
+	return creds.query(ctx, "PATCH", creds.issueAPIURL(repo, number), map[string]interface{}{
+		"body": body,
+	}, &issue)
+}
+
+func (creds GithubCredentials) commentIssue(ctx context.Context, repo string, todo Todo, comment string) error {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return err
+	}
+
+	response := struct{}{}
+	return creds.query(ctx, "POST", creds.issueAPIURL(repo, number)+"/comments", map[string]interface{}{
+		"body": comment,
+	}, &response)
+}
+
+func (creds GithubCredentials) permalink(repo string, commit string, filename string, line int) string {
+	return "https://" + creds.Host + "/" + repo + "/blob/" + commit + "/" + escapeFilename(filename) + "#L" + strconv.Itoa(line)
 }
 
 func (creds GithubCredentials) getHost() string {
-	return "github.com"
+	return creds.Host
 }
 
-// GithubCredentialsFromFile gets GithubCredentials from a filepath
-func GithubCredentialsFromFile(filepath string) (GithubCredentials, error) {
+// GithubCredentialsFromFile gets GithubCredentials from a filepath.
+// The [github] section is the github.com token, the rest of the
+// sections are named after the GitHub Enterprise Server hosts.
+func GithubCredentialsFromFile(filepath string) []GithubCredentials {
+	credentials := []GithubCredentials{}
+
 	cfg, err := ini.Load(filepath)
 	if err != nil {
-		return GithubCredentials{}, err
+		return credentials
 	}
 
-	return GithubCredentials{
-		PersonalToken: cfg.Section("github").Key("personal_token").String(),
-	}, nil
+	for _, section := range cfg.Sections()[1:] {
+		host := section.Name()
+		if host == "github" {
+			host = githubHost
+		}
+
+		credentials = append(credentials, GithubCredentials{
+			Host:          host,
+			PersonalToken: section.Key("personal_token").String(),
+		})
+	}
+
+	return credentials
 }
 
 // GithubCredentialsFromToken returns a GithubCredentials from a string token
-func GithubCredentialsFromToken(token string) GithubCredentials {
-	return GithubCredentials{
-		PersonalToken: token,
+func GithubCredentialsFromToken(token string) (GithubCredentials, error) {
+	credentials := strings.Split(token, ":")
+
+	switch len(credentials) {
+	case 1:
+		return GithubCredentials{
+			Host:          githubHost,
+			PersonalToken: credentials[0],
+		}, nil
+	case 2:
+		return GithubCredentials{
+			Host:          credentials[0],
+			PersonalToken: credentials[1],
+		}, nil
+	default:
+		return GithubCredentials{},
+			fmt.Errorf("Couldn't parse GitHub credentials from ENV: %s", token)
 	}
 }
 
-func getGithubCredentials() (GithubCredentials, error) {
+func getGithubCredentials(creds []IssueAPI) []IssueAPI {
 	tokenEnvar := os.Getenv("GITHUB_PERSONAL_TOKEN")
 	xdgEnvar := os.Getenv("XDG_CONFIG_HOME")
 	usr, err := user.Current()
@@ -98,14 +371,23 @@ func getGithubCredentials() (GithubCredentials, error) {
 	}
 
 	if len(tokenEnvar) != 0 {
-		return GithubCredentialsFromToken(tokenEnvar), nil
+		for _, credential := range strings.Split(tokenEnvar, ",") {
+			parsedCredentials, err := GithubCredentialsFromToken(credential)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			creds = append(creds, parsedCredentials)
+		}
 	}
 
 	// custom XDG_CONFIG_HOME
 	if len(xdgEnvar) != 0 {
 		filePath := path.Join(xdgEnvar, "snitch/github.ini")
 		if _, err := os.Stat(filePath); err == nil {
-			return GithubCredentialsFromFile(filePath)
+			for _, cred := range GithubCredentialsFromFile(filePath) {
+				creds = append(creds, cred)
+			}
 		}
 	}
 
@@ -113,14 +395,18 @@ func getGithubCredentials() (GithubCredentials, error) {
 	if len(xdgEnvar) == 0 {
 		filePath := path.Join(usr.HomeDir, ".config/snitch/github.ini")
 		if _, err := os.Stat(filePath); err == nil {
-			return GithubCredentialsFromFile(filePath)
+			for _, cred := range GithubCredentialsFromFile(filePath) {
+				creds = append(creds, cred)
+			}
 		}
 	}
 
 	filePath := path.Join(usr.HomeDir, ".snitch/github.ini")
 	if _, err := os.Stat(filePath); err == nil {
-		return GithubCredentialsFromFile(filePath)
+		for _, cred := range GithubCredentialsFromFile(filePath) {
+			creds = append(creds, cred)
+		}
 	}
 
-	return GithubCredentials{}, fmt.Errorf("GitHub token is missing")
+	return creds
 }
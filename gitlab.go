@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -14,57 +15,252 @@ import (
 )
 
 // GitlabCredentials contains PersonalToken for GitLab API authorization
-// and Host for possibly implementing support for self-hosted instances
+// and Host and Instance for self-hosted instances
 type GitlabCredentials struct {
 	Host          string
 	PersonalToken string
+	Instance
 }
 
-func (creds GitlabCredentials) query(method, url string) (map[string]interface{}, error) {
-	req, err := http.NewRequest(method, url, nil)
+func (creds GitlabCredentials) newRequest(ctx context.Context, method, url string) (*http.Request, *http.Client, error) {
+	client, err := creds.client()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, nil, err
 	}
 	req.Header.Add("PRIVATE-TOKEN", creds.PersonalToken)
 
-	return QueryHTTP(req)
+	return req, client, nil
 }
 
-func (creds GitlabCredentials) getIssue(repo string, todo Todo) (map[string]interface{}, error) {
-	json, err := creds.query(
-		"GET",
-		// FIXME(#156): possible GitLab API injection attack
-		"https://"+creds.Host+"/api/v4/projects/"+url.QueryEscape(repo)+"/issues/"+(*todo.ID)[1:]) // self-hosted
+// gitlabIssue is the part of a GitLab issue snitch cares about
+type gitlabIssue struct {
+	IID   *int   `json:"iid"`
+	Title string `json:"title"`
+	// State is either opened or closed
+	State     string `json:"state"`
+	Assignees []struct {
+		Username string `json:"username"`
+	} `json:"assignees"`
+	Labels []string `json:"labels"`
+}
+
+func (issue gitlabIssue) normalize() (Issue, error) {
+	if issue.IID == nil {
+		return Issue{}, errMissingField("GitLab", "iid")
+	}
+
+	if len(issue.State) == 0 {
+		return Issue{}, errMissingField("GitLab", "state")
+	}
+
+	var assignees []string
+	for _, assignee := range issue.Assignees {
+		assignees = append(assignees, assignee.Username)
+	}
+
+	return Issue{
+		ID:        "#" + strconv.Itoa(*issue.IID),
+		Title:     issue.Title,
+		State:     issueState(issue.State == issueClosed),
+		Assignees: assignees,
+		Labels:    issue.Labels,
+	}, nil
+}
+
+func (creds GitlabCredentials) query(ctx context.Context, method, url string, v interface{}) error {
+	req, client, err := creds.newRequest(ctx, method, url)
+	if err != nil {
+		return err
+	}
+
+	return QueryHTTPWithClient(client, req, v)
+}
+
+func (creds GitlabCredentials) apiURL() string {
+	return creds.baseURL(creds.Host) + "/api/v4"
+}
+
+// issueAPIURL is the URL of the issue in the API. GitLab takes the
+// whole path of the project as a single URL encoded segment.
+func (creds GitlabCredentials) issueAPIURL(repo string, number int) string {
+	return creds.apiURL() + "/projects/" + url.QueryEscape(repo) + "/issues/" + strconv.Itoa(number)
+}
+
+func (creds GitlabCredentials) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return Issue{}, err
+	}
 
+	issue := gitlabIssue{}
+	err = creds.query(ctx, "GET", creds.issueAPIURL(repo, number), &issue)
 	if err != nil {
-		return nil, err
+		return Issue{}, err
 	}
 
-	return json, nil
+	return issue.normalize()
 }
 
-func (creds GitlabCredentials) postIssue(repo string, todo Todo, body string) (Todo, error) {
+// findUserID finds the ID of the user because GitLab assigns the
+// issues by IDs instead of usernames
+func (creds GitlabCredentials) findUserID(ctx context.Context, username string) (int, error) {
+	users := []struct {
+		ID *int `json:"id"`
+	}{}
+	err := creds.query(ctx,
+		"GET",
+		creds.apiURL()+"/users?username="+url.QueryEscape(username),
+		&users)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(users) == 0 {
+		return 0, fmt.Errorf("GitLab user %s is not found", username)
+	}
+
+	if users[0].ID == nil {
+		return 0, errMissingField("GitLab", "id")
+	}
+
+	return *users[0].ID, nil
+}
+
+func (creds GitlabCredentials) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
 	params := url.Values{}
 	params.Add("title", todo.Title)
 	params.Add("description", body)
 
-	json, err := creds.query(
+	if len(todo.Assignee) > 0 {
+		userID, err := creds.findUserID(ctx, todo.Assignee)
+		if err != nil {
+			return Issue{}, err
+		}
+		params.Add("assignee_ids[]", strconv.Itoa(userID))
+	}
+
+	issue := gitlabIssue{}
+	err := creds.query(ctx,
 		"POST",
-		"https://"+creds.Host+"/api/v4/projects/"+url.QueryEscape(repo)+"/issues?"+params.Encode()) // self-hosted
+		creds.apiURL()+"/projects/"+url.QueryEscape(repo)+"/issues?"+params.Encode(),
+		&issue)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return issue.normalize()
+}
+
+// gitlabBatchSize is the maximum page size of the GitLab API
+const gitlabBatchSize = 100
+
+// getIssues filters the issues of the project by their iids, a page
+// of them per request
+func (creds GitlabCredentials) getIssues(ctx context.Context, repo string, todos []Todo) ([]Issue, error) {
+	numbers := issueNumbers(todos)
+	issues := []Issue{}
+
+	for start := 0; start < len(numbers); start += gitlabBatchSize {
+		end := start + gitlabBatchSize
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+
+		params := url.Values{}
+		params.Add("state", "all")
+		params.Add("per_page", strconv.Itoa(gitlabBatchSize))
+		for _, number := range numbers[start:end] {
+			params.Add("iids[]", strconv.Itoa(number))
+		}
+
+		page := []gitlabIssue{}
+		err := creds.query(ctx,
+			"GET",
+			creds.apiURL()+"/projects/"+url.QueryEscape(repo)+"/issues?"+params.Encode(),
+			&page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, issue := range page {
+			normalized, err := issue.normalize()
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, normalized)
+		}
+	}
+
+	return issues, nil
+}
+
+func (creds GitlabCredentials) getIssueBody(ctx context.Context, repo string, todo Todo) (string, error) {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return "", err
+	}
+
+	issue := struct {
+		Description string `json:"description"`
+	}{}
+	err = creds.query(ctx, "GET", creds.issueAPIURL(repo, number), &issue)
+	return issue.Description, err
+}
+
+func (creds GitlabCredentials) editIssueBody(ctx context.Context, repo string, todo Todo, body string) error {
+	number, err := issueNumber(todo)
 	if err != nil {
-		return todo, err
+		return err
 	}
 
-	id := "#" + strconv.Itoa(int(json["iid"].(float64)))
-	todo.ID = &id
+	params := url.Values{}
+	params.Add("description", body)
+
+	issue := gitlabIssue{}
+	return creds.query(ctx, "PUT", creds.issueAPIURL(repo, number)+"?"+params.Encode(), &issue)
+}
+
+func (creds GitlabCredentials) commentIssue(ctx context.Context, repo string, todo Todo, comment string) error {
+	number, err := issueNumber(todo)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Add("body", comment)
+
+	response := struct{}{}
+	return creds.query(ctx, "POST", creds.issueAPIURL(repo, number)+"/notes?"+params.Encode(), &response)
+}
 
-	return todo, err
+func (creds GitlabCredentials) permalink(repo string, commit string, filename string, line int) string {
+	return creds.baseURL(creds.Host) + "/" + repo + "/-/blob/" + commit + "/" + escapeFilename(filename) + "#L" + strconv.Itoa(line)
 }
 
 func (creds GitlabCredentials) getHost() string {
 	return creds.Host
 }
 
+// matchRemote extracts the path of the project from the remote URL.
+// Unlike the repos of the other trackers the GitLab projects may be
+// nested in subgroups, like group/sub/project.
+func (creds GitlabCredentials) matchRemote(remoteURL string) (string, bool) {
+	return matchHostRepo(creds.Host, creds.stripPathPrefix(remoteURL), `(?:[-.\w]+/)+[-.\w]+`)
+}
+
+func (creds GitlabCredentials) projectURL(repo string) string {
+	return creds.baseURL(creds.Host) + "/" + repo
+}
+
+func (creds GitlabCredentials) issueURL(repo string, todo Todo) string {
+	return creds.baseURL(creds.Host) + "/" + repo + "/-/issues/" + strings.TrimPrefix(*todo.ID, "#")
+}
+
 // GitlabCredentialsFromFile gets GitlabCredentials from a filepath
 func GitlabCredentialsFromFile(filepath string) []GitlabCredentials {
 	credentials := []GitlabCredentials{}
@@ -78,6 +274,7 @@ func GitlabCredentialsFromFile(filepath string) []GitlabCredentials {
 		credentials = append(credentials, GitlabCredentials{
 			Host:          section.Name(),
 			PersonalToken: section.Key("personal_token").String(),
+			Instance:      instanceFromSection(section),
 		})
 	}
 
@@ -86,6 +283,14 @@ func GitlabCredentialsFromFile(filepath string) []GitlabCredentials {
 
 // GitlabCredentialsFromToken returns a GitlabCredentials from a string token
 func GitlabCredentialsFromToken(token string) (GitlabCredentials, error) {
+	if instance, host, personalToken, ok := instanceFromToken(token); ok {
+		return GitlabCredentials{
+			Host:          host,
+			PersonalToken: personalToken,
+			Instance:      instance,
+		}, nil
+	}
+
 	credentials := strings.Split(token, ":")
 
 	switch len(credentials) {
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const gitRefIssuesNamespace = "refs/snitch/issues/"
+
+// gitRefIDLength is how many hex digits of the hash of the first commit
+// of the issue make its ID
+const gitRefIDLength = 8
+
+// gitRefIDRegexp matches the IDs of the issues. The issues created by
+// the older versions of snitch are numbered.
+var gitRefIDRegexp = regexp.MustCompile(`^[0-9a-f]{1,40}$`)
+
+// GitRefTracker stores issues in git refs so they travel with `git
+// push` and work offline. Each issue is a chain of commits with an
+// empty tree under refs/snitch/issues/<id>. The message of the
+// latest commit is the current state of the issue in the same
+// format as the files of the LocalTracker preceded by a summary line
+// for `git log`. The ID is the short hash of the first commit of the
+// issue, so the clones that report offline can't make the same ID for
+// different issues.
+type GitRefTracker struct{}
+
+func runGit(stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	var outb, errb bytes.Buffer
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(errb.String()))
+	}
+
+	return strings.TrimSpace(outb.String()), nil
+}
+
+func (tracker GitRefTracker) issueRef(id string) string {
+	return gitRefIssuesNamespace + id
+}
+
+func (tracker GitRefTracker) parseID(todo Todo) (string, error) {
+	ref, err := parseIssueKey(*todo.ID, "#", gitRefIDRegexp)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a git ref issue ID", *todo.ID)
+	}
+
+	return ref.Key, nil
+}
+
+func (tracker GitRefTracker) readIssue(id string) (LocalIssue, string, error) {
+	if _, err := runGit("", "rev-parse", "--verify", "--quiet", tracker.issueRef(id)); err != nil {
+		return LocalIssue{}, "", fmt.Errorf("Issue #%s is not found in %s: %w", id, gitRefIssuesNamespace, ErrNotFound)
+	}
+
+	message, err := runGit("", "log", "-1", "--format=%B", tracker.issueRef(id), "--")
+	if err != nil {
+		return LocalIssue{}, "", err
+	}
+
+	// Skipping the summary line
+	if summaryEnd := strings.Index(message, "\n\n"); summaryEnd >= 0 {
+		message = message[summaryEnd+2:]
+	}
+
+	issue, body, err := parseLocalIssue([]byte(message + "\n"))
+	if err != nil {
+		return issue, "", fmt.Errorf("%s: %w", tracker.issueRef(id), err)
+	}
+
+	return issue, body, nil
+}
+
+// writeIssue records the new state of the issue on top of its
+// history. oldCommit is the expected current commit of the issue ref
+// or an empty string for a new issue, whose ID is made out of its
+// first commit. Returns the ID.
+func (tracker GitRefTracker) writeIssue(id string, issue LocalIssue, body string, oldCommit string) (string, error) {
+	content, err := formatLocalIssue(issue, body)
+	if err != nil {
+		return "", err
+	}
+	message := fmt.Sprintf("[%s] %s\n\n%s", issue.State, issue.Title, content)
+
+	emptyTree, err := runGit("", "mktree")
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"commit-tree", emptyTree}
+	if len(oldCommit) > 0 {
+		args = append(args, "-p", oldCommit)
+	}
+
+	commit, err := runGit(message, append(args, "-F", "-")...)
+	if err != nil {
+		return "", err
+	}
+
+	if len(oldCommit) == 0 {
+		id = commit[:gitRefIDLength]
+	}
+
+	// update-ref fails if somebody else has changed the ref in the
+	// meantime or the new issue already exists
+	_, err = runGit("", "update-ref", "-m", "snitch", tracker.issueRef(id), commit, oldCommit)
+	return id, err
+}
+
+// asIssue is LocalIssue.asIssue with the ID of the ref. The issues of
+// the older versions of snitch still have their numbers in the message.
+func (tracker GitRefTracker) asIssue(id string, issue LocalIssue) Issue {
+	normalized := issue.asIssue()
+	normalized.ID = "#" + id
+	return normalized
+}
+
+func (tracker GitRefTracker) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	id, err := tracker.parseID(todo)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	issue, _, err := tracker.readIssue(id)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return tracker.asIssue(id, issue), nil
+}
+
+func (tracker GitRefTracker) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	issue := LocalIssue{
+		Title:    todo.Title,
+		State:    "open",
+		Assignee: todo.Assignee,
+		// The nanoseconds keep the first commits of the same
+		// issues reported one after another apart
+		Created: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	id, err := tracker.writeIssue("", issue, body, "")
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return tracker.asIssue(id, issue), nil
+}
+
+// CloseIssue marks the issue of the todo as closed
+func (tracker GitRefTracker) CloseIssue(todo Todo) error {
+	id, err := tracker.parseID(todo)
+	if err != nil {
+		return err
+	}
+
+	oldCommit, err := runGit("", "rev-parse", "--verify", tracker.issueRef(id))
+	if err != nil {
+		return err
+	}
+
+	issue, body, err := tracker.readIssue(id)
+	if err != nil {
+		return err
+	}
+
+	issue.State = "closed"
+	_, err = tracker.writeIssue(id, issue, body, oldCommit)
+	return err
+}
+
+func (tracker GitRefTracker) getHost() string {
+	return "git"
+}
+
+func (tracker GitRefTracker) projectURL(repo string) string {
+	return gitRefIssuesNamespace
+}
+
+func (tracker GitRefTracker) issueURL(repo string, todo Todo) string {
+	return gitRefIssuesNamespace + strings.TrimPrefix(*todo.ID, "#")
+}
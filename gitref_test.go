@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitRefTracker(t *testing.T) {
+	_, cleanup := chdirTempGitRepo(t, map[string]string{
+		"main.go": "package main\n",
+	})
+	defer cleanup()
+
+	tracker := GitRefTracker{}
+
+	ids := []string{}
+	for i := 0; i < 2; i++ {
+		todo, err := Todo{Title: "Rewrite this in Rust"}.Report(context.Background(), tracker, gitRefIssuesNamespace, "No really.")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id := derefString(todo.ID)
+		if _, err := tracker.parseID(todo); err != nil || len(id) != gitRefIDLength+1 {
+			t.Errorf("got ID %q, want # and %d hex digits", id, gitRefIDLength)
+		}
+		ids = append(ids, id)
+	}
+
+	if ids[0] == ids[1] {
+		t.Errorf("the same issues reported one after another got the same ID %s", ids[0])
+	}
+
+	todo := Todo{ID: stringPtr(ids[1])}
+
+	if status, err := todo.RetrieveStatus(context.Background(), tracker, gitRefIssuesNamespace, nil); err != nil || status != "open" {
+		t.Fatalf("got status %q (%v), want %q", status, err, "open")
+	}
+
+	if err := tracker.CloseIssue(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, err := todo.RetrieveStatus(context.Background(), tracker, gitRefIssuesNamespace, nil); err != nil || status != "closed" {
+		t.Fatalf("got status %q (%v), want %q", status, err, "closed")
+	}
+
+	_, body, err := tracker.readIssue(strings.TrimPrefix(ids[1], "#"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body != "No really." {
+		t.Errorf("got body %q, want %q", body, "No really.")
+	}
+
+	if _, err := (Todo{ID: stringPtr("#3")}).RetrieveStatus(context.Background(), tracker, gitRefIssuesNamespace, nil); err == nil {
+		t.Errorf("expected an error for a non-existing issue")
+	}
+}
+
+func TestGitRefTracker_Clones(t *testing.T) {
+	dir, cleanup := chdirTempGitRepo(t, map[string]string{
+		"main.go": "package main\n",
+	})
+	defer cleanup()
+
+	clone, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(clone)
+
+	clone = filepath.Join(clone, "clone")
+	git(t, "clone", "-q", dir, clone)
+
+	tracker := GitRefTracker{}
+	report := func() string {
+		todo, err := Todo{Title: "Rewrite this in Rust"}.Report(context.Background(), tracker, gitRefIssuesNamespace, "No really.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return derefString(todo.ID)
+	}
+
+	// Both clones report offline
+	original := report()
+	if err := os.Chdir(clone); err != nil {
+		t.Fatal(err)
+	}
+	cloned := report()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if original == cloned {
+		t.Fatalf("the clones made the same ID %s", original)
+	}
+
+	// The issues of the clone travel without overwriting the others
+	git(t, "fetch", "-q", clone, "refs/snitch/*:refs/snitch/*")
+	for _, id := range []string{original, cloned} {
+		if _, err := tracker.getIssue(context.Background(), gitRefIssuesNamespace, Todo{ID: stringPtr(id)}); err != nil {
+			t.Errorf("%s: %s", id, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ignoreFiles are the files consulted in every directory by the
+// filesystem walker when the project is not a git repo (exported
+// tarballs, Mercurial checkouts, etc)
+var ignoreFiles = []string{".gitignore"}
+
+// hgignoreFile is only consulted at the root of the project, the same
+// way Mercurial does
+const hgignoreFile = ".hgignore"
+
+// vcsDirs are never walked into by the filesystem walker
+var vcsDirs = []string{".git", ".hg", ".svn", ".bzr"}
+
+type ignoreRule struct {
+	base    string
+	regexp  *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	// basename rules are matched against the last path segment only
+	basename bool
+}
+
+func (rule ignoreRule) matches(relPath string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+
+	if len(rule.base) > 0 {
+		if !strings.HasPrefix(relPath, rule.base+"/") {
+			return false
+		}
+		relPath = strings.TrimPrefix(relPath, rule.base+"/")
+	}
+
+	if rule.basename {
+		return rule.regexp.MatchString(path.Base(relPath))
+	}
+
+	return rule.regexp.MatchString(relPath)
+}
+
+// globToRegexp translates a gitignore-style glob into an anchored
+// regular expression
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				sb.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			if end := strings.IndexByte(glob[i:], ']'); end > 0 {
+				class := glob[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				sb.WriteString("[" + class + "]")
+				i += end
+			} else {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	// Matching a directory also matches everything inside of it
+	sb.WriteString("(/.*)?$")
+	return sb.String()
+}
+
+func parseGlobRule(base string, pattern string) *ignoreRule {
+	rule := &ignoreRule{base: base}
+
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	if len(pattern) == 0 {
+		return nil
+	}
+
+	if strings.HasPrefix(pattern, "/") {
+		pattern = pattern[1:]
+	} else if !strings.Contains(pattern, "/") {
+		rule.basename = true
+	}
+
+	re, err := regexp.Compile(globToRegexp(pattern))
+	if err != nil {
+		return nil
+	}
+	rule.regexp = re
+
+	return rule
+}
+
+// loadIgnoreFile parses .gitignore and .hgignore files. The rules of
+// the file are relative to base. Unparsable lines are skipped.
+func loadIgnoreFile(filePath string, base string) []ignoreRule {
+	rules := []ignoreRule{}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return rules
+	}
+	defer file.Close()
+
+	// .hgignore uses regexp syntax by default
+	hgSyntax := ""
+	if path.Base(filePath) == hgignoreFile {
+		hgSyntax = "regexp"
+	}
+
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if len(hgSyntax) > 0 {
+			if strings.HasPrefix(line, "syntax:") {
+				hgSyntax = strings.TrimSpace(strings.TrimPrefix(line, "syntax:"))
+				continue
+			}
+
+			if hgSyntax == "regexp" || hgSyntax == "re" {
+				re, err := regexp.Compile(line)
+				if err != nil {
+					continue
+				}
+				rules = append(rules, ignoreRule{base: base, regexp: re})
+				continue
+			}
+		}
+
+		if rule := parseGlobRule(base, line); rule != nil {
+			rules = append(rules, *rule)
+		}
+	}
+
+	return rules
+}
+
+// isIgnored checks relPath against the rules. The last matching rule wins.
+func isIgnored(rules []ignoreRule, relPath string, isDir bool) bool {
+	for _, dir := range vcsDirs {
+		if isDir && path.Base(relPath) == dir {
+			return true
+		}
+	}
+
+	ignored := false
+	for _, rule := range rules {
+		if rule.matches(relPath, isDir) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIsIgnored(t *testing.T) {
+	rules := []ignoreRule{}
+	for _, pattern := range []string{"*.o", "build/", "/TAGS", "doc/**/*.html", "!keep.o"} {
+		rules = append(rules, *parseGlobRule("", pattern))
+	}
+	rules = append(rules, *parseGlobRule("sub", "local.txt"))
+
+	tests := []struct {
+		path  string
+		isDir bool
+		out   bool
+	}{
+		{"main.o", false, true},
+		{"src/main.o", false, true},
+		{"keep.o", false, false},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false},
+		{"TAGS", false, true},
+		{"src/TAGS", false, false},
+		{"doc/index.html", false, true},
+		{"doc/api/index.html", false, true},
+		{"src/index.html", false, false},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{".git", true, true},
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := isIgnored(rules, tt.path, tt.isDir); got != tt.out {
+				t.Errorf("got %t, want %t", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestWalkTodosOfDir_HgignoreAtRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".hg/requires":     "store\n",
+		".hgignore":        "syntax: glob\n*.tmp\n",
+		"main.go":          "// TODO: root\n",
+		"main.tmp":         "// TODO: ignored by the root .hgignore\n",
+		"sub/.hgignore":    "syntax: glob\n*.go\n",
+		"sub/sub.go":       "// TODO: Mercurial doesn't read sub/.hgignore\n",
+		"sub/sub.tmp":      "// TODO: ignored by the root .hgignore\n",
+		"sub/.gitignore":   "local.go\n",
+		"sub/local.go":     "// TODO: ignored by sub/.gitignore\n",
+		"sub/deeper/x.tmp": "// TODO: ignored by the root .hgignore\n",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	project := Project{
+		Title:    &TitleConfig{},
+		Keywords: []string{"TODO"},
+	}
+
+	for _, tt := range []struct {
+		dir  string
+		want []string
+	}{
+		{dir, []string{"root", "Mercurial doesn't read sub/.hgignore"}},
+		// The root .hgignore applies when walking a subdirectory too
+		{filepath.Join(dir, "sub"), []string{"Mercurial doesn't read sub/.hgignore"}},
+	} {
+		got := []string{}
+		err := project.WalkTodosOfDir(tt.dir, func(todo Todo) error {
+			got = append(got, todo.Suffix)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sort.Strings(got)
+		sort.Strings(tt.want)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Instance contains the connection settings of a self-hosted tracker
+// instance: http instances, custom ports, reverse proxies with a path
+// prefix and internal TLS certificates
+type Instance struct {
+	// BaseURL like http://gitlab.local:8080/gitlab. By default
+	// it's https://<host>
+	BaseURL string
+	// CAFile is a PEM bundle trusted in addition to the system roots
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+func instanceFromSection(section *ini.Section) Instance {
+	return Instance{
+		BaseURL:            section.Key("base_url").String(),
+		CAFile:             section.Key("ca_file").String(),
+		InsecureSkipVerify: section.Key("insecure_skip_verify").MustBool(false),
+	}
+}
+
+// instanceFromToken splits `<base-url>:<token>` where base-url has a
+// scheme. Returns false if the string doesn't start with a scheme.
+func instanceFromToken(token string) (Instance, string, string, bool) {
+	if !strings.HasPrefix(token, "http://") && !strings.HasPrefix(token, "https://") {
+		return Instance{}, "", "", false
+	}
+
+	separator := strings.LastIndex(token, ":")
+	baseURL, err := url.Parse(token[:separator])
+	if err != nil || len(baseURL.Host) == 0 {
+		return Instance{}, "", "", false
+	}
+
+	return Instance{BaseURL: token[:separator]}, baseURL.Hostname(), token[separator+1:], true
+}
+
+func (instance Instance) baseURL(host string) string {
+	if len(instance.BaseURL) > 0 {
+		return strings.TrimSuffix(instance.BaseURL, "/")
+	}
+
+	return "https://" + host
+}
+
+// stripPathPrefix removes the path of BaseURL from the http remotes of
+// the instances behind a reverse proxy, so
+// https://git.corp/gitlab/owner/repo becomes
+// https://git.corp/owner/repo. The ssh remotes don't have the prefix.
+func (instance Instance) stripPathPrefix(remoteURL string) string {
+	baseURL, err := url.Parse(instance.BaseURL)
+	if err != nil {
+		return remoteURL
+	}
+
+	prefix := strings.Trim(baseURL.Path, "/")
+	if len(prefix) == 0 {
+		return remoteURL
+	}
+
+	remote, err := url.Parse(remoteURL)
+	if err != nil || (remote.Scheme != "http" && remote.Scheme != "https") {
+		return remoteURL
+	}
+
+	if !strings.HasPrefix(remote.Path, "/"+prefix+"/") {
+		return remoteURL
+	}
+
+	remote.Path = strings.TrimPrefix(remote.Path, "/"+prefix)
+	return remote.String()
+}
+
+func (instance Instance) client() (*http.Client, error) {
+	if len(instance.CAFile) == 0 && !instance.InsecureSkipVerify {
+		return newHTTPClient(nil), nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: instance.InsecureSkipVerify,
+	}
+
+	if len(instance.CAFile) > 0 {
+		pem, err := ioutil.ReadFile(instance.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s doesn't contain any PEM certificates", instance.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return newHTTPClient(tlsConfig), nil
+}
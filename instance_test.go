@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestGitlabCredentialsFromToken_BaseURL(t *testing.T) {
+	creds, err := GitlabCredentialsFromToken("http://gitlab.local:8080/gitlab:secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := GitlabCredentials{
+		Host:          "gitlab.local",
+		PersonalToken: "secret",
+		Instance:      Instance{BaseURL: "http://gitlab.local:8080/gitlab"},
+	}
+
+	if creds != want {
+		t.Errorf("got %+v, want %+v", creds, want)
+	}
+
+	if got := creds.apiURL(); got != "http://gitlab.local:8080/gitlab/api/v4" {
+		t.Errorf("got API URL %q", got)
+	}
+}
+
+func TestMatchRepo_PathPrefix(t *testing.T) {
+	instance := Instance{BaseURL: "https://git.corp/gitlab"}
+	gitlab := GitlabCredentials{Host: "git.corp", Instance: instance}
+	gitea := GiteaCredentials{Host: "git.corp", Instance: Instance{BaseURL: "https://git.corp/gitea/"}}
+
+	tests := []struct {
+		creds IssueAPI
+		url   string
+		repo  string
+		ok    bool
+	}{
+		{gitlab, "https://git.corp/gitlab/owner/repo.git", "owner/repo", true},
+		{gitlab, "https://alice@git.corp/gitlab/group/sub/project.git", "group/sub/project", true},
+		{gitlab, "git@git.corp:gitlab/project.git", "gitlab/project", true},
+		{gitea, "https://git.corp/gitea/owner/repo.git", "owner/repo", true},
+		{gitea, "ssh://git@git.corp:2222/owner/repo.git", "owner/repo", true},
+		{gitea, "https://git.corp/other/owner/repo.git", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			repo, ok := matchRepo(tt.creds, tt.url)
+			if repo != tt.repo || ok != tt.ok {
+				t.Errorf("got %q %v, want %q %v", repo, ok, tt.repo, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGiteaCredentials_CustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gitea/api/v1/repos/alice/snitch/issues/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"number": 1, "state": "closed"}`))
+	}))
+	defer server.Close()
+
+	caFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+
+	err = pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err != nil {
+		log.Fatal(err)
+	}
+	caFile.Close()
+
+	todo := Todo{ID: stringPtr("#1")}
+
+	untrusted := GiteaCredentials{
+		Host:     "gitea.local",
+		Instance: Instance{BaseURL: server.URL + "/gitea/"},
+	}
+	if _, err := todo.RetrieveStatus(context.Background(), untrusted, "alice/snitch", nil); err == nil {
+		t.Errorf("expected the self-signed certificate to be rejected")
+	}
+
+	for _, instance := range []Instance{
+		{BaseURL: server.URL + "/gitea/", CAFile: caFile.Name()},
+		{BaseURL: server.URL + "/gitea", InsecureSkipVerify: true},
+	} {
+		creds := GiteaCredentials{Host: "gitea.local", Instance: instance}
+
+		status, err := todo.RetrieveStatus(context.Background(), creds, "alice/snitch", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if status != "closed" {
+			t.Errorf("got status %q, want %q", status, "closed")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// updateLock is held while a reported or purged TODO is written to the
+// file and committed. An interrupt waits for it so the issue that has
+// just been created never ends up without its ID in the code.
+var updateLock sync.Mutex
+
+// interruptContext returns the context cancelled on Ctrl-C. The
+// in-flight requests are cancelled right away and snitch exits as
+// soon as no file is being updated.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		cancel()
+
+		// The second Ctrl-C kills snitch the usual way
+		signal.Stop(signals)
+
+		updateLock.Lock()
+		fmt.Fprintln(os.Stderr, "\nInterrupted")
+		os.Exit(130)
+	}()
+
+	return ctx
+}
+
+// setHTTPTimeout applies --timeout <duration> like 10s or 2m
+func setHTTPTimeout(params map[string]string) error {
+	value, ok := params["timeout"]
+	if !ok {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("--timeout expects a positive duration like 10s or 2m, got `%s'", value)
+	}
+
+	httpTimeout = timeout
+
+	return nil
+}
@@ -1,40 +1,301 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
 // IssueAPI requires implementing common API for querying and posting issues
-// regardless of service that's being used.
+// regardless of service that's being used. The requests are cancelled
+// along with ctx.
 type IssueAPI interface {
-	getIssue(repo string, todo Todo) (map[string]interface{}, error)
-	postIssue(repo string, todo Todo, body string) (Todo, error)
+	getIssue(ctx context.Context, repo string, todo Todo) (Issue, error)
+	postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error)
 	getHost() string
 }
 
-// QueryHTTP makes an API query
-func QueryHTTP(req *http.Request) (map[string]interface{}, error) {
-	client := &http.Client{}
+// The states of Issue. Every tracker brings its own states down to
+// these two.
+const (
+	issueOpen   = "open"
+	issueClosed = "closed"
+)
+
+// Issue is an issue of any of the trackers normalized to what the rest
+// of snitch understands
+type Issue struct {
+	// ID is how the TODOs refer to the issue, like #42 or PROJ-42
+	ID    string
+	Title string
+	State string
+	// Assignees and Labels are only filled by the trackers that
+	// have them
+	Assignees []string `json:",omitempty"`
+	Labels    []string `json:",omitempty"`
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// issueState maps the closedness of an issue to its normalized state
+func issueState(closed bool) string {
+	if closed {
+		return issueClosed
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(resp.Body)
-		return nil, fmt.Errorf("API error: %s", buf.String())
+	return issueOpen
+}
+
+// errMissingField reports a response of the tracker that doesn't have
+// a field snitch relies on
+func errMissingField(tracker string, field string) error {
+	return fmt.Errorf("%s API response has no `%s' field", tracker, field)
+}
+
+// IssueBatcher is implemented by the trackers that can get many issues
+// of a repo in a single request. The issues that are not found are
+// simply missing in the result.
+type IssueBatcher interface {
+	getIssues(ctx context.Context, repo string, todos []Todo) ([]Issue, error)
+}
+
+// RemoteMatcher is implemented by the trackers whose remote URLs
+// don't follow the <host>[:/]<owner>/<repo> layout
+type RemoteMatcher interface {
+	matchRemote(url string) (string, bool)
+}
+
+// ProjectConfigurer is implemented by the trackers that take
+// additional configuration from .snitch.yaml
+type ProjectConfigurer interface {
+	configure(project Project, repo string) (string, IssueAPI)
+}
+
+// IssueCloser is implemented by the trackers that don't have their
+// own interface for closing the issues
+type IssueCloser interface {
+	CloseIssue(todo Todo) error
+}
+
+// IssueFiler is implemented by the trackers that keep the issues as
+// files of the repo, so they're committed along with the todos
+type IssueFiler interface {
+	issueFile(todo Todo) string
+}
+
+// IssueLinker is implemented by the trackers whose web interface
+// doesn't follow the https://<host>/<repo>/issues/<number> layout
+type IssueLinker interface {
+	projectURL(repo string) string
+	issueURL(repo string, todo Todo) string
+}
+
+// getIssues gets the issues of the todos in as few requests as the
+// tracker allows, keyed by the IDs of the todos. The issues missing in
+// the batched responses are asked one by one, the ones that don't
+// exist are missing in the result. When the batched request fails the
+// issues are asked one by one as well, unless the request was cancelled.
+func getIssues(ctx context.Context, creds IssueAPI, repo string, todos []Todo) (map[string]Issue, error) {
+	issues := map[string]Issue{}
+
+	if batcher, ok := creds.(IssueBatcher); ok && len(todos) > 1 {
+		batch, err := batcher.getIssues(ctx, repo, todos)
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Couldn't get the issues of %s in a batch, asking them one by one: %s\n", repo, err)
+		}
+
+		for _, issue := range batch {
+			issues[issue.ID] = issue
+		}
 	}
 
-	var v map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
-		return nil, err
+	notFound := map[string]bool{}
+	for _, todo := range todos {
+		if _, ok := issues[*todo.ID]; ok || notFound[*todo.ID] {
+			continue
+		}
+
+		issue, err := creds.getIssue(ctx, repo, todo)
+		if errors.Is(err, ErrNotFound) {
+			notFound[*todo.ID] = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		issues[*todo.ID] = issue
 	}
 
-	return v, err
+	return issues, nil
+}
+
+// issueNumbers extracts the distinct numbers of the #N IDs. The IDs of
+// the other kinds are skipped.
+func issueNumbers(todos []Todo) []int {
+	numbers := []int{}
+	seen := map[int]bool{}
+
+	for _, todo := range todos {
+		number, err := issueNumber(todo)
+		if err != nil || seen[number] {
+			continue
+		}
+
+		seen[number] = true
+		numbers = append(numbers, number)
+	}
+
+	return numbers
+}
+
+func projectURL(creds IssueAPI, repo string) string {
+	if linker, ok := creds.(IssueLinker); ok {
+		return linker.projectURL(repo)
+	}
+
+	return fmt.Sprintf("https://%s/%s", creds.getHost(), repo)
+}
+
+func issueURL(creds IssueAPI, repo string, todo Todo) string {
+	if linker, ok := creds.(IssueLinker); ok {
+		return linker.issueURL(repo, todo)
+	}
+
+	return fmt.Sprintf("https://%s/%s/issues/%s",
+		creds.getHost(), repo, strings.TrimPrefix(*todo.ID, "#"))
+}
+
+// Retrying the rate limited requests and the server errors.
+// maxRetryWait is the longest wait snitch agrees to, the longer rate
+// limit resets are reported as errors instead.
+const (
+	maxQueryAttempts = 4
+	maxRetryWait     = time.Minute
+)
+
+// defaultHTTPTimeout limits a single request to a tracker including
+// reading the response
+const defaultHTTPTimeout = 30 * time.Second
+
+// httpTimeout is defaultHTTPTimeout unless overridden with --timeout
+var httpTimeout = defaultHTTPTimeout
+
+// queryBackoff is the wait before the first retry, doubled on every
+// next one. Only changed in tests along with sleep.
+var (
+	queryBackoff = time.Second
+	sleep        = sleepContext
+)
+
+// sleepContext waits for d unless ctx is cancelled earlier
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newHTTPClient makes the client for the tracker APIs. The proxy is
+// taken from HTTPS_PROXY, HTTP_PROXY and NO_PROXY (or their lowercase
+// versions). tlsConfig may be nil.
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   httpTimeout,
+	}
+}
+
+// QueryHTTP makes an API query decoding the response into v
+func QueryHTTP(req *http.Request, v interface{}) error {
+	return QueryHTTPWithClient(newHTTPClient(nil), req, v)
+}
+
+// QueryHTTPWithClient makes an API query with a custom client
+// decoding the response into v. The error responses are reported as
+// *APIError. The GET requests are conditional if the context has the
+// validators of the cached response, errNotModified means the cached
+// response is still valid.
+func QueryHTTPWithClient(client *http.Client, req *http.Request, v interface{}) error {
+	conditional := conditionalRequestFrom(req.Context())
+	if req.Method != "GET" {
+		conditional = nil
+	}
+
+	if conditional != nil {
+		if len(conditional.ETag) > 0 {
+			req.Header.Set("If-None-Match", conditional.ETag)
+		}
+		if len(conditional.LastModified) > 0 {
+			req.Header.Set("If-Modified-Since", conditional.LastModified)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if conditional != nil && resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			return errNotModified
+		}
+
+		if resp.StatusCode < 400 {
+			defer resp.Body.Close()
+
+			if conditional != nil {
+				conditional.ETag = resp.Header.Get("ETag")
+				conditional.LastModified = resp.Header.Get("Last-Modified")
+			}
+
+			return json.NewDecoder(resp.Body).Decode(v)
+		}
+
+		apiErr := newAPIError(resp)
+		resp.Body.Close()
+
+		if attempt == maxQueryAttempts || !apiErr.retryable(req.Method) {
+			return apiErr
+		}
+
+		wait := apiErr.RetryAfter
+		if wait == 0 {
+			wait = queryBackoff << uint(attempt-1)
+		}
+		if wait > maxRetryWait {
+			return apiErr
+		}
+
+		// The body is already consumed by the previous attempt
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return apiErr
+			}
+			if req.Body, err = req.GetBody(); err != nil {
+				return err
+			}
+		}
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return err
+		}
+	}
 }
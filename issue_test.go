@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIssue_NormalizeResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		issue    interface{ normalize() (Issue, error) }
+		want     Issue
+		wantErr  bool
+	}{
+		{"github", `{"number": 42, "title": "foo", "state": "closed"}`, &githubIssue{}, Issue{ID: "#42", Title: "foo", State: issueClosed}, false},
+		{"github without number", `{"title": "foo", "state": "open"}`, &githubIssue{}, Issue{}, true},
+		{"github with string number", `{"number": "42", "state": "open"}`, &githubIssue{}, Issue{}, true},
+		{"gitlab", `{"iid": 7, "title": "foo", "state": "opened"}`, &gitlabIssue{}, Issue{ID: "#7", Title: "foo", State: issueOpen}, false},
+		{"github assignees and labels", `{"number": 1, "state": "open", "assignees": [{"login": "alice"}], "labels": [{"name": "bug"}]}`, &githubIssue{}, Issue{ID: "#1", State: issueOpen, Assignees: []string{"alice"}, Labels: []string{"bug"}}, false},
+		{"github graphql", `{"number": 1, "state": "MERGED", "assignees": {"nodes": [{"login": "alice"}]}, "labels": {"nodes": []}}`, &githubGraphQLIssue{}, Issue{ID: "#1", State: issueClosed, Assignees: []string{"alice"}}, false},
+		{"gitlab assignees and labels", `{"iid": 7, "state": "opened", "assignees": [{"username": "bob"}], "labels": ["bug", "ui"]}`, &gitlabIssue{}, Issue{ID: "#7", State: issueOpen, Assignees: []string{"bob"}, Labels: []string{"bug", "ui"}}, false},
+		{"gitlab without state", `{"iid": 7}`, &gitlabIssue{}, Issue{}, true},
+		{"gitea index", `{"index": 3, "state": "Closed"}`, &giteaIssue{}, Issue{ID: "#3", Title: "", State: issueClosed}, false},
+		{"gogs assignee", `{"number": 3, "state": "open", "assignee": {"login": "carol"}}`, &giteaIssue{}, Issue{ID: "#3", State: issueOpen, Assignees: []string{"carol"}}, false},
+		{"gitea without number", `{"state": "open"}`, &giteaIssue{}, Issue{}, true},
+		{"bitbucket", `{"id": 5, "state": "wontfix"}`, &bitbucketIssue{}, Issue{ID: "#5", Title: "", State: issueClosed}, false},
+		{"bitbucket error", `{"type": "error"}`, &bitbucketIssue{}, Issue{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := json.Unmarshal([]byte(tt.response), tt.issue)
+			var issue Issue
+			if err == nil {
+				issue, err = tt.issue.normalize()
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %v", issue)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(issue, tt.want) {
+				t.Errorf("got %v, want %v", issue, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryHTTP_HungTracker(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var v interface{}
+		if err := QueryHTTP(req, &v); !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		oldTimeout := httpTimeout
+		httpTimeout = 50 * time.Millisecond
+		defer func() { httpTimeout = oldTimeout }()
+
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var v interface{}
+		err = QueryHTTP(req, &v)
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Errorf("got error %v, want a timeout", err)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// issueNumberRegexp matches the #N IDs of the issues of the current
+// repo. The leading zeros would make #007 and #7 different IDs of the
+// same issue.
+var issueNumberRegexp = regexp.MustCompile(`^#([1-9][0-9]{0,8})$`)
+
+// crossRepoIDRegexp matches the IDs that refer to the issues of the
+// other repos of the same tracker, like owner/repo#42,
+// group/sub/project#7 or ~owner/tracker#3
+var crossRepoIDRegexp = regexp.MustCompile(`^((?:[-.\w~]+/)+[-.\w~]+)#([1-9][0-9]{0,8})$`)
+
+// issueURLRegexp matches the links to the issues, like
+// https://github.com/owner/repo/issues/42 or
+// https://gitlab.com/group/sub/project/-/issues/7. Bitbucket puts the
+// title after the number.
+var issueURLRegexp = regexp.MustCompile(`^https?://([-.\w]+(?::[0-9]+)?)/((?:[-.\w~]+/)*[-.\w~]+)/issues/([1-9][0-9]{0,8})(?:/[^/]*)?$`)
+
+// safeSegmentRegexp matches the path segments that can be put into
+// the API URLs as is
+var safeSegmentRegexp = regexp.MustCompile(`^[-.\w~]+$`)
+
+func isSafeSegment(segment string) bool {
+	return safeSegmentRegexp.MatchString(segment) && segment != "." && segment != ".."
+}
+
+func isSafeRepo(repo string) bool {
+	for _, segment := range strings.Split(repo, "/") {
+		if !isSafeSegment(segment) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IssueRef is the parsed and validated ID of a reported todo. The
+// backends build the API URLs out of it instead of the ID itself, so a
+// crafted TODO(#../../..) can't reach the other endpoints.
+type IssueRef struct {
+	// Host is only set by the links to the issues
+	Host string
+	// Repo is empty for the issues of the current repo
+	Repo   string
+	Number int
+	// Key is set instead of Number by the trackers whose IDs are keys
+	// like PROJ-42 rather than the numbers of the repo issues
+	Key string
+}
+
+// parseIssueRef parses #42, owner/repo#42 and the links to the issues
+func parseIssueRef(id string) (IssueRef, error) {
+	if groups := issueNumberRegexp.FindStringSubmatch(id); groups != nil {
+		number, err := strconv.Atoi(groups[1])
+		return IssueRef{Number: number}, err
+	}
+
+	ref := IssueRef{}
+	var number string
+	if groups := crossRepoIDRegexp.FindStringSubmatch(id); groups != nil {
+		ref.Repo, number = groups[1], groups[2]
+	} else if groups := issueURLRegexp.FindStringSubmatch(id); groups != nil {
+		ref.Host, ref.Repo, number = groups[1], strings.TrimSuffix(groups[2], "/-"), groups[3]
+	} else {
+		return IssueRef{}, fmt.Errorf("`%s' is not an issue reference like #42, owner/repo#42 or a link to the issue", id)
+	}
+
+	if !isSafeRepo(ref.Repo) {
+		return IssueRef{}, fmt.Errorf("`%s' refers to an invalid repo %s", id, ref.Repo)
+	}
+
+	var err error
+	ref.Number, err = strconv.Atoi(number)
+	return ref, err
+}
+
+// parseIssueKey parses the key style IDs like PROJ-42 that follow the
+// prefix. The key must match keyRegexp unless it's nil and is always a
+// single path segment.
+func parseIssueKey(id string, prefix string, keyRegexp *regexp.Regexp) (IssueRef, error) {
+	key := strings.TrimPrefix(id, prefix)
+	if !strings.HasPrefix(id, prefix) || !isSafeSegment(key) ||
+		(keyRegexp != nil && !keyRegexp.MatchString(key)) {
+		return IssueRef{}, fmt.Errorf("`%s' is not an issue key", id)
+	}
+
+	return IssueRef{Key: key}, nil
+}
+
+// ID is how the current repo refers to the issue
+func (ref IssueRef) ID() string {
+	if len(ref.Key) > 0 {
+		return ref.Key
+	}
+
+	return "#" + strconv.Itoa(ref.Number)
+}
+
+// issueNumber is the number of the issue of the current repo the todo
+// refers to. The only IDs the trackers are asked about.
+func issueNumber(todo Todo) (int, error) {
+	ref, err := parseIssueRef(*todo.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ref.Repo) > 0 {
+		return 0, fmt.Errorf("`%s' is not an issue of the current repo", *todo.ID)
+	}
+
+	return ref.Number, nil
+}
+
+// IssueResolver finds the tracker and the repo of the issue a todo
+// refers to. Unless CrossRepo is set all of the issues belong to Repo.
+type IssueResolver struct {
+	Creds     IssueAPI
+	Repo      string
+	CrossRepo bool
+
+	// credentials are the trackers the links to the other hosts
+	// are resolved against
+	credentials func() []IssueAPI
+}
+
+func newIssueResolver(project Project, creds IssueAPI, repo string) IssueResolver {
+	var loaded []IssueAPI
+
+	return IssueResolver{
+		Creds: creds,
+		Repo:  repo,
+		// Only the trackers found by the remote have other repos.
+		// The ones from .snitch.yaml have a single project.
+		CrossRepo: len(project.Tracker) == 0,
+		credentials: func() []IssueAPI {
+			if loaded == nil {
+				loaded = getCredentials()
+			}
+			return loaded
+		},
+	}
+}
+
+// resolve returns the tracker, the repo and the todo with the ID the
+// tracker understands, like #42
+func (resolver IssueResolver) resolve(todo Todo) (IssueAPI, string, Todo, error) {
+	if !resolver.CrossRepo {
+		return resolver.Creds, resolver.Repo, todo, nil
+	}
+
+	// The rest of the IDs are up to the tracker
+	ref, err := parseIssueRef(*todo.ID)
+	if err != nil || len(ref.Repo) == 0 {
+		return resolver.Creds, resolver.Repo, todo, nil
+	}
+
+	creds := resolver.Creds
+	if len(ref.Host) > 0 {
+		creds, err = resolver.credsOfHost(ref.Host)
+		if err != nil {
+			return nil, "", todo, err
+		}
+	}
+
+	id := ref.ID()
+	todo.ID = &id
+	return creds, ref.Repo, todo, nil
+}
+
+func (resolver IssueResolver) credsOfHost(host string) (IssueAPI, error) {
+	if resolver.Creds.getHost() == host {
+		return resolver.Creds, nil
+	}
+
+	if resolver.credentials != nil {
+		for _, creds := range resolver.credentials() {
+			if creds.getHost() == host {
+				return creds, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("No credentials have been found for %s", host)
+}
+
+// issueURL is the link to the issue of the todo. The todos that can't
+// be resolved are the links already.
+func (resolver IssueResolver) issueURL(todo Todo) string {
+	creds, repo, todo, err := resolver.resolve(todo)
+	if err != nil {
+		return *todo.ID
+	}
+
+	return issueURL(creds, repo, todo)
+}
+
+// issueGroup is the todos that refer to the issues of the same repo
+type issueGroup struct {
+	creds IssueAPI
+	repo  string
+	todos []Todo
+	// ids are the IDs of the todos as they are written in the code
+	ids []string
+}
+
+// getIssues gets the issues of the todos that may refer to the issues
+// of the different repos. The todos are grouped by the repo, so the
+// batches still work. The issues are keyed by the IDs as they are
+// written in the todos. The todos that can't be resolved are reported
+// and left out like the missing issues. Any other error stops.
+func (resolver IssueResolver) getIssues(ctx context.Context, cache *IssueCache, todos []Todo) (map[string]Issue, error) {
+	return resolver.collectIssues(ctx, cache, todos, func(ids []string, err error) error {
+		return err
+	})
+}
+
+// collectIssues is getIssues that leaves the fate of the repos that
+// failed up to onError. It gets the IDs of the todos of the repo and
+// the error, and stops everything by returning an error.
+func (resolver IssueResolver) collectIssues(ctx context.Context, cache *IssueCache, todos []Todo, onError func(ids []string, err error) error) (map[string]Issue, error) {
+	groups := []*issueGroup{}
+	groupOf := map[string]*issueGroup{}
+
+	for _, todo := range todos {
+		creds, repo, resolved, err := resolver.resolve(todo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] %s: %s\n", todo.LogString(), err)
+			continue
+		}
+
+		key := creds.getHost() + "/" + repo
+		group, ok := groupOf[key]
+		if !ok {
+			group = &issueGroup{creds: creds, repo: repo}
+			groupOf[key] = group
+			groups = append(groups, group)
+		}
+
+		group.todos = append(group.todos, resolved)
+		group.ids = append(group.ids, *todo.ID)
+	}
+
+	issues := map[string]Issue{}
+	for _, group := range groups {
+		found, err := cache.getIssues(ctx, group.creds, group.repo, group.todos)
+		if err != nil {
+			if err := onError(group.ids, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for i, todo := range group.todos {
+			if issue, ok := found[*todo.ID]; ok {
+				issues[group.ids[i]] = issue
+			}
+		}
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIssueResolver_Resolve(t *testing.T) {
+	github := GithubCredentials{Host: githubHost}
+	gitlab := GitlabCredentials{Host: "gitlab.com"}
+	resolver := IssueResolver{
+		Creds:     github,
+		Repo:      "tsoding/snitch",
+		CrossRepo: true,
+		credentials: func() []IssueAPI {
+			return []IssueAPI{github, gitlab}
+		},
+	}
+
+	tests := []struct {
+		id       string
+		wantHost string
+		wantRepo string
+		wantID   string
+		wantErr  bool
+	}{
+		{"#42", githubHost, "tsoding/snitch", "#42", false},
+		{"tsoding/ded#42", githubHost, "tsoding/ded", "#42", false},
+		{"group/sub/project#7", githubHost, "group/sub/project", "#7", false},
+		{"~alice/snitch#3", githubHost, "~alice/snitch", "#3", false},
+		{"https://github.com/tsoding/ded/issues/42", githubHost, "tsoding/ded", "#42", false},
+		{"https://gitlab.com/group/sub/project/-/issues/7", "gitlab.com", "group/sub/project", "#7", false},
+		{"https://gitlab.com/group/project/issues/7", "gitlab.com", "group/project", "#7", false},
+		{"https://bitbucket.org/workspace/repo/issues/5/some-title", "", "", "", true},
+		{"PROJ-42", githubHost, "tsoding/snitch", "PROJ-42", false},
+		{"tsoding/ded#abc", githubHost, "tsoding/snitch", "tsoding/ded#abc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			creds, repo, todo, err := resolver.resolve(Todo{ID: stringPtr(tt.id)})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %s %s %s", creds.getHost(), repo, *todo.ID)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if creds.getHost() != tt.wantHost || repo != tt.wantRepo || *todo.ID != tt.wantID {
+				t.Errorf("got %s %s %s, want %s %s %s",
+					creds.getHost(), repo, *todo.ID,
+					tt.wantHost, tt.wantRepo, tt.wantID)
+			}
+		})
+	}
+
+	// The trackers from .snitch.yaml don't have other repos
+	resolver.CrossRepo = false
+	if _, repo, todo, _ := resolver.resolve(Todo{ID: stringPtr("tsoding/ded#42")}); repo != "tsoding/snitch" || *todo.ID != "tsoding/ded#42" {
+		t.Errorf("got %s %s", repo, *todo.ID)
+	}
+}
+
+func TestIssueResolver_GetIssues(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+
+		parts := strings.Split(r.URL.Path, "/")
+		state := "open"
+		if parts[5] == "other" {
+			state = "closed"
+		}
+		fmt.Fprintf(w, `{"number": %s, "state": "%s"}`, parts[len(parts)-1], state)
+	}))
+	defer server.Close()
+
+	creds := GiteaCredentials{Host: "gitea.example.com", Instance: Instance{BaseURL: server.URL}}
+	resolver := IssueResolver{Creds: creds, Repo: "alice/snitch", CrossRepo: true}
+
+	todos := []Todo{
+		{ID: stringPtr("#1")},
+		{ID: stringPtr("alice/other#1")},
+		{ID: stringPtr("https://gitea.example.com/bob/other/issues/2")},
+		{ID: stringPtr("https://unknown.example.com/bob/other/issues/3"), Keyword: "TODO"},
+	}
+
+	issues, err := resolver.getIssues(context.Background(), nil, todos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"#1":            issueOpen,
+		"alice/other#1": issueClosed,
+		"https://gitea.example.com/bob/other/issues/2": issueClosed,
+	}
+	if len(issues) != len(want) {
+		t.Errorf("got %v, want %v", issues, want)
+	}
+	for id, state := range want {
+		if issues[id].State != state {
+			t.Errorf("%s: got %s, want %s", id, issues[id].State, state)
+		}
+	}
+
+	wantRequests := []string{
+		"/api/v1/repos/alice/snitch/issues/1",
+		"/api/v1/repos/alice/other/issues/1",
+		"/api/v1/repos/bob/other/issues/2",
+	}
+	if strings.Join(requests, " ") != strings.Join(wantRequests, " ") {
+		t.Errorf("got %v, want %v", requests, wantRequests)
+	}
+}
+
+func TestParseIssueRef(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    IssueRef
+		wantErr bool
+	}{
+		{"#42", IssueRef{Number: 42}, false},
+		{"tsoding/ded#42", IssueRef{Repo: "tsoding/ded", Number: 42}, false},
+		{"https://gitea.example.com:3000/alice/snitch/issues/1", IssueRef{Host: "gitea.example.com:3000", Repo: "alice/snitch", Number: 1}, false},
+		{"#../../..", IssueRef{}, true},
+		{"#42/../../user", IssueRef{}, true},
+		{"#42?state=closed", IssueRef{}, true},
+		{"#42#", IssueRef{}, true},
+		{"#-1", IssueRef{}, true},
+		{"#+1", IssueRef{}, true},
+		{"#007", IssueRef{}, true},
+		{"#0", IssueRef{}, true},
+		{"#99999999999999999999", IssueRef{}, true},
+		{"42", IssueRef{}, true},
+		{"../..#1", IssueRef{}, true},
+		{"owner/.#1", IssueRef{}, true},
+		{"owner//repo#1", IssueRef{}, true},
+		{"https://github.com/../../issues/1", IssueRef{}, true},
+		{"https://github.com@evil.com/owner/repo/issues/1", IssueRef{}, true},
+		{"https://github.com/owner/repo%2F..%2F../issues/1", IssueRef{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			ref, err := parseIssueRef(tt.id)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", ref)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ref != tt.want {
+				t.Errorf("got %+v, want %+v", ref, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIssueKey(t *testing.T) {
+	tests := []struct {
+		id      string
+		prefix  string
+		want    IssueRef
+		wantErr bool
+	}{
+		{"PROJ-42", "", IssueRef{Key: "PROJ-42"}, false},
+		{"#abc-1", "#", IssueRef{Key: "abc-1"}, false},
+		{"abc-1", "#", IssueRef{}, true},
+		{"PROJ-42/../../user", "", IssueRef{}, true},
+		{"PROJ-42?fields=*all", "", IssueRef{}, true},
+		{"..", "", IssueRef{}, true},
+		{"#..", "#", IssueRef{}, true},
+		{"", "", IssueRef{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			ref, err := parseIssueKey(tt.id, tt.prefix, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", ref)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ref != tt.want {
+				t.Errorf("got %+v, want %+v", ref, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseIssueKey("proj-42", "", jiraIssueKeyRegexp); err == nil {
+		t.Errorf("accepted the key that doesn't match the pattern")
+	}
+}
+
+func TestGetIssue_InvalidIDs(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"number": 1, "iid": 1, "id": 1, "state": "open"}`))
+	}))
+	defer server.Close()
+
+	_, cleanup := chdirTempGitRepo(t, map[string]string{
+		"main.go": "package main\n",
+	})
+	defer cleanup()
+
+	generic := GenericTracker{Config: GenericConfig{
+		Get: GenericGetConfig{
+			GenericRequestConfig: GenericRequestConfig{URL: server.URL + "/issues/{{.ID}}"},
+			State:                "state",
+		},
+	}}
+
+	trackers := []struct {
+		tracker IssueAPI
+		repo    string
+	}{
+		{GithubCredentials{Host: githubHost, restURL: server.URL}, "alice/snitch"},
+		{GiteaCredentials{Host: "gitea.example.com", Instance: Instance{BaseURL: server.URL}}, "alice/snitch"},
+		{GitlabCredentials{Host: "gitlab.example.com", Instance: Instance{BaseURL: server.URL}}, "alice/snitch"},
+		{BitbucketCredentials{apiURL: server.URL}, "alice/snitch"},
+		{AzureCredentials{apiURL: server.URL}, "alice/snitch"},
+		{RedmineCredentials{apiURL: server.URL}, "alice/snitch"},
+		{SourcehutCredentials{apiURL: server.URL}, "~alice/snitch"},
+		{JiraCredentials{apiURL: server.URL}, "PROJ"},
+		{generic, "alice/snitch"},
+		{LocalTracker{Dir: defaultLocalIssuesDir}, defaultLocalIssuesDir},
+		{GitRefTracker{}, gitRefIssuesNamespace},
+	}
+
+	ids := []string{
+		"#../../user", "#1/../../user", "#1?x=y", "tsoding/ded#1",
+		"PROJ-1/../../user", "PROJ-1?fields=*all", "../PROJ-1", "..",
+	}
+
+	for _, id := range ids {
+		for _, tt := range trackers {
+			if _, err := tt.tracker.getIssue(context.Background(), tt.repo, Todo{ID: stringPtr(id)}); err == nil {
+				t.Errorf("%T accepted %s", tt.tracker, id)
+			}
+		}
+	}
+
+	if requests != 0 {
+		t.Errorf("%d requests were made with the invalid IDs", requests)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const defaultJiraIssueType = "Task"
+
+var jiraIssueKeyRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`)
+
+// JiraConfig contains the project level configuration of the Jira tracker
+type JiraConfig struct {
+	Host      string
+	Project   string
+	IssueType string `yaml:"issue_type"`
+	// ClosedStatusCategories lists the status categories (new,
+	// indeterminate, done) or the status names that are considered
+	// closed
+	ClosedStatusCategories []string `yaml:"closed_status_categories"`
+}
+
+// JiraCredentials contains either Email and APIToken (Jira Cloud) or
+// PersonalToken (Jira Server/Data Center) for Jira API authorization
+type JiraCredentials struct {
+	Host          string
+	Email         string
+	APIToken      string
+	PersonalToken string
+
+	IssueType              string
+	ClosedStatusCategories []string
+
+	// apiURL is only overridden in tests
+	apiURL string
+}
+
+func (creds JiraCredentials) baseURL() string {
+	if len(creds.apiURL) > 0 {
+		return creds.apiURL
+	}
+
+	return "https://" + creds.Host
+}
+
+func (creds JiraCredentials) isCloud() bool {
+	return len(creds.APIToken) > 0
+}
+
+// jiraIssue is the part of a Jira issue snitch cares about. Creating
+// an issue only responds with the key.
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary  string      `json:"summary"`
+		Status   *jiraStatus `json:"status"`
+		Assignee *struct {
+			// Name is gone from Jira Cloud
+			Name        string `json:"name"`
+			DisplayName string `json:"displayName"`
+		} `json:"assignee"`
+		Labels []string `json:"labels"`
+	} `json:"fields"`
+}
+
+type jiraStatus struct {
+	Name           string `json:"name"`
+	StatusCategory struct {
+		Key string `json:"key"`
+	} `json:"statusCategory"`
+}
+
+func (creds JiraCredentials) query(ctx context.Context, method, url string, jsonBody map[string]interface{}, v interface{}) error {
+	bodyBuffer := new(bytes.Buffer)
+	err := json.NewEncoder(bodyBuffer).Encode(jsonBody)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyBuffer)
+	if err != nil {
+		return err
+	}
+
+	if creds.isCloud() {
+		req.SetBasicAuth(creds.Email, creds.APIToken)
+	} else {
+		req.Header.Add("Authorization", "Bearer "+creds.PersonalToken)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	return QueryHTTP(req, v)
+}
+
+func (creds JiraCredentials) isClosedStatus(status jiraStatus) bool {
+	closedStatusCategories := creds.ClosedStatusCategories
+	if len(closedStatusCategories) == 0 {
+		closedStatusCategories = []string{"done"}
+	}
+
+	for _, closed := range closedStatusCategories {
+		if strings.EqualFold(closed, status.StatusCategory.Key) || strings.EqualFold(closed, status.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (creds JiraCredentials) getIssue(ctx context.Context, project string, todo Todo) (Issue, error) {
+	ref, err := parseIssueKey(*todo.ID, "", jiraIssueKeyRegexp)
+	if err != nil {
+		return Issue{}, fmt.Errorf("%s is not a Jira issue key", *todo.ID)
+	}
+
+	issue := jiraIssue{}
+	err = creds.query(ctx,
+		"GET",
+		creds.baseURL()+"/rest/api/2/issue/"+ref.Key+"?fields=summary,status,assignee,labels",
+		nil,
+		&issue)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	if issue.Fields.Status == nil {
+		return Issue{}, errMissingField("Jira", "fields.status")
+	}
+
+	var assignees []string
+	if assignee := issue.Fields.Assignee; assignee != nil {
+		name := assignee.Name
+		if len(name) == 0 {
+			name = assignee.DisplayName
+		}
+		assignees = append(assignees, name)
+	}
+
+	return Issue{
+		ID:        *todo.ID,
+		Title:     issue.Fields.Summary,
+		State:     issueState(creds.isClosedStatus(*issue.Fields.Status)),
+		Assignees: assignees,
+		Labels:    issue.Fields.Labels,
+	}, nil
+}
+
+func (creds JiraCredentials) postIssue(ctx context.Context, project string, todo Todo, body string) (Issue, error) {
+	issueType := creds.IssueType
+	if len(issueType) == 0 {
+		issueType = defaultJiraIssueType
+	}
+
+	fields := map[string]interface{}{
+		"project": map[string]interface{}{
+			"key": project,
+		},
+		"summary":     todo.Title,
+		"description": body,
+		"issuetype": map[string]interface{}{
+			"name": issueType,
+		},
+	}
+
+	if len(todo.Assignee) > 0 {
+		// Jira Cloud doesn't accept usernames anymore
+		if creds.isCloud() {
+			fields["assignee"] = map[string]interface{}{"accountId": todo.Assignee}
+		} else {
+			fields["assignee"] = map[string]interface{}{"name": todo.Assignee}
+		}
+	}
+
+	issue := jiraIssue{}
+	err := creds.query(ctx,
+		"POST",
+		creds.baseURL()+"/rest/api/2/issue",
+		map[string]interface{}{"fields": fields},
+		&issue)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	if len(issue.Key) == 0 {
+		return Issue{}, errMissingField("Jira", "key")
+	}
+
+	return Issue{
+		ID:    issue.Key,
+		Title: todo.Title,
+		State: issueOpen,
+	}, nil
+}
+
+func (creds JiraCredentials) getHost() string {
+	return creds.Host
+}
+
+func (creds JiraCredentials) projectURL(project string) string {
+	return "https://" + creds.Host + "/browse/" + project
+}
+
+func (creds JiraCredentials) issueURL(project string, todo Todo) string {
+	return "https://" + creds.Host + "/browse/" + *todo.ID
+}
+
+// JiraCredentialsFromFile gets JiraCredentials from a filepath
+func JiraCredentialsFromFile(filepath string) []JiraCredentials {
+	credentials := []JiraCredentials{}
+
+	cfg, err := ini.Load(filepath)
+	if err != nil {
+		return credentials
+	}
+
+	for _, section := range cfg.Sections()[1:] {
+		credentials = append(credentials, JiraCredentials{
+			Host:          section.Name(),
+			Email:         section.Key("email").String(),
+			APIToken:      section.Key("api_token").String(),
+			PersonalToken: section.Key("personal_token").String(),
+		})
+	}
+
+	return credentials
+}
+
+// JiraCredentialsFromToken returns a JiraCredentials from either
+// `<host>:<email>:<api-token>` or `<host>:<personal-token>` string
+func JiraCredentialsFromToken(token string) (JiraCredentials, error) {
+	credentials := strings.Split(token, ":")
+
+	switch len(credentials) {
+	case 2:
+		return JiraCredentials{
+			Host:          credentials[0],
+			PersonalToken: credentials[1],
+		}, nil
+	case 3:
+		return JiraCredentials{
+			Host:     credentials[0],
+			Email:    credentials[1],
+			APIToken: credentials[2],
+		}, nil
+	default:
+		return JiraCredentials{},
+			fmt.Errorf("Couldn't parse Jira credentials from ENV: %s", token)
+	}
+}
+
+func getJiraCredentials() []JiraCredentials {
+	creds := []JiraCredentials{}
+	tokenEnvar := os.Getenv("JIRA_API_TOKEN")
+	xdgEnvar := os.Getenv("XDG_CONFIG_HOME")
+	usr, err := user.Current()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(tokenEnvar) != 0 {
+		for _, credential := range strings.Split(tokenEnvar, ",") {
+			parsedCredentials, err := JiraCredentialsFromToken(credential)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			creds = append(creds, parsedCredentials)
+		}
+	}
+
+	// custom XDG_CONFIG_HOME
+	if len(xdgEnvar) != 0 {
+		filePath := path.Join(xdgEnvar, "snitch/jira.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			creds = append(creds, JiraCredentialsFromFile(filePath)...)
+		}
+	}
+
+	// default XDG_CONFIG_HOME
+	if len(xdgEnvar) == 0 {
+		filePath := path.Join(usr.HomeDir, ".config/snitch/jira.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			creds = append(creds, JiraCredentialsFromFile(filePath)...)
+		}
+	}
+
+	filePath := path.Join(usr.HomeDir, ".snitch/jira.ini")
+	if _, err := os.Stat(filePath); err == nil {
+		creds = append(creds, JiraCredentialsFromFile(filePath)...)
+	}
+
+	return creds
+}
+
+// getJiraTracker finds the credentials for the Jira instance
+// configured in .snitch.yaml. The "repo" of a Jira tracker is the
+// key of the Jira project.
+func getJiraTracker(config *JiraConfig) (string, IssueAPI, error) {
+	if config == nil || len(config.Host) == 0 || len(config.Project) == 0 {
+		return "", nil, fmt.Errorf("Jira tracker requires `jira.host' and `jira.project' in .snitch.yaml")
+	}
+
+	for _, creds := range getJiraCredentials() {
+		if creds.Host == config.Host {
+			creds.IssueType = config.IssueType
+			creds.ClosedStatusCategories = config.ClosedStatusCategories
+			return config.Project, creds, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("No Jira credentials have been found for %s. Read https://github.com/tsoding/snitch#jira-credentials", config.Host)
+}
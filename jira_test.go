@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJiraCredentials(t *testing.T) {
+	statuses := map[string]string{
+		"PROJ-1": `{"name": "Done", "statusCategory": {"key": "done"}}`,
+		"PROJ-2": `{"name": "In Review", "statusCategory": {"key": "indeterminate"}}`,
+		"PROJ-3": `{"name": "Won't Do", "statusCategory": {"key": "new"}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if email, token, ok := r.BasicAuth(); !ok || email != "alice@example.com" || token != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method == "POST" && r.URL.Path == "/rest/api/2/issue" {
+			var issue struct {
+				Fields struct {
+					Project   struct{ Key string }
+					Summary   string
+					IssueType struct{ Name string }
+				}
+			}
+			if err := json.NewDecoder(r.Body).Decode(&issue); err != nil ||
+				issue.Fields.Project.Key != "PROJ" || issue.Fields.IssueType.Name != "Bug" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "10000", "key": "PROJ-4"}`))
+			return
+		}
+
+		if status, ok := statuses[r.URL.Path[len("/rest/api/2/issue/"):]]; ok && r.Method == "GET" {
+			w.Write([]byte(`{"key": "PROJ", "fields": {"status": ` + status + `}}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	creds := JiraCredentials{
+		Host:                   "example.atlassian.net",
+		Email:                  "alice@example.com",
+		APIToken:               "secret",
+		IssueType:              "Bug",
+		ClosedStatusCategories: []string{"done", "won't do"},
+		apiURL:                 server.URL,
+	}
+
+	todo, err := Todo{Title: "Rewrite this in Rust"}.Report(context.Background(), creds, "PROJ", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := derefString(todo.ID); got != "PROJ-4" {
+		t.Errorf("got ID %q, want %q", got, "PROJ-4")
+	}
+
+	tests := []struct {
+		id     string
+		status string
+	}{
+		{"PROJ-1", "closed"},
+		{"PROJ-2", "open"},
+		{"PROJ-3", "closed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			status, err := Todo{ID: stringPtr(tt.id)}.RetrieveStatus(context.Background(), creds, "PROJ", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if status != tt.status {
+				t.Errorf("got %q, want %q", status, tt.status)
+			}
+		})
+	}
+
+	if _, err := (Todo{ID: stringPtr("#42")}).RetrieveStatus(context.Background(), creds, "PROJ", nil); err == nil {
+		t.Errorf("expected an error for a non-Jira issue key")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultLocalIssuesDir = ".issues"
+
+// LocalConfig contains the project level configuration of the local tracker
+type LocalConfig struct {
+	Dir string
+}
+
+// LocalIssue is the front matter of an issue stored as a Markdown file
+type LocalIssue struct {
+	ID       int      `yaml:"id,omitempty"`
+	Title    string   `yaml:"title"`
+	State    string   `yaml:"state"`
+	Assignee string   `yaml:"assignee,omitempty"`
+	Labels   []string `yaml:"labels,omitempty"`
+	Created  string   `yaml:"created"`
+}
+
+// LocalTracker stores issues as Markdown files with YAML front matter
+// in a directory. Useful for air-gapped work and for testing.
+type LocalTracker struct {
+	Dir string
+}
+
+func (tracker LocalTracker) issuePath(id int) string {
+	return path.Join(tracker.Dir, fmt.Sprintf("%d.md", id))
+}
+
+// asIssue normalizes the issue like the API responses of the hosted trackers
+func (issue LocalIssue) asIssue() Issue {
+	var assignees []string
+	if len(issue.Assignee) > 0 {
+		assignees = append(assignees, issue.Assignee)
+	}
+
+	return Issue{
+		ID:        "#" + strconv.Itoa(issue.ID),
+		Title:     issue.Title,
+		State:     issueState(strings.EqualFold(issue.State, issueClosed)),
+		Assignees: assignees,
+		Labels:    issue.Labels,
+	}
+}
+
+func parseLocalIssue(content []byte) (LocalIssue, string, error) {
+	issue := LocalIssue{}
+
+	parts := bytes.SplitN(content, []byte("---\n"), 3)
+	if len(parts) != 3 || len(parts[0]) != 0 {
+		return issue, "", fmt.Errorf("the front matter is missing")
+	}
+
+	if err := yaml.Unmarshal(parts[1], &issue); err != nil {
+		return issue, "", err
+	}
+
+	return issue, strings.TrimSpace(string(parts[2])), nil
+}
+
+func formatLocalIssue(issue LocalIssue, body string) ([]byte, error) {
+	frontMatter, err := yaml.Marshal(issue)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("---\n%s---\n\n%s\n", frontMatter, strings.TrimSpace(body))), nil
+}
+
+func (tracker LocalTracker) readIssue(id int) (LocalIssue, error) {
+	filePath := tracker.issuePath(id)
+
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return LocalIssue{}, fmt.Errorf("Issue #%d is not found in %s: %w", id, tracker.Dir, ErrNotFound)
+	}
+	if err != nil {
+		return LocalIssue{}, err
+	}
+
+	issue, _, err := parseLocalIssue(content)
+	if err != nil {
+		return issue, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	return issue, nil
+}
+
+// nextID allocates the next sequential issue ID
+func (tracker LocalTracker) nextID() (int, error) {
+	entries, err := ioutil.ReadDir(tracker.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	maxID := 0
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".md"))
+		if err == nil && id > maxID {
+			maxID = id
+		}
+	}
+
+	return maxID + 1, nil
+}
+
+func (tracker LocalTracker) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	id, err := issueNumber(todo)
+	if err != nil {
+		return Issue{}, fmt.Errorf("%s is not a local issue ID", *todo.ID)
+	}
+
+	issue, err := tracker.readIssue(id)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return issue.asIssue(), nil
+}
+
+func (tracker LocalTracker) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	if err := os.MkdirAll(tracker.Dir, 0755); err != nil {
+		return Issue{}, err
+	}
+
+	issue := LocalIssue{
+		Title:    todo.Title,
+		State:    "open",
+		Assignee: todo.Assignee,
+		Created:  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	for {
+		var err error
+		issue.ID, err = tracker.nextID()
+		if err != nil {
+			return Issue{}, err
+		}
+
+		content, err := formatLocalIssue(issue, body)
+		if err != nil {
+			return Issue{}, err
+		}
+
+		// O_EXCL makes sure two concurrent reports don't get the same ID
+		file, err := os.OpenFile(tracker.issuePath(issue.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return Issue{}, err
+		}
+
+		_, err = file.Write(content)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return Issue{}, err
+		}
+
+		break
+	}
+
+	return issue.asIssue(), nil
+}
+
+// CloseIssue marks the issue of the todo as closed
+func (tracker LocalTracker) CloseIssue(todo Todo) error {
+	id, err := issueNumber(todo)
+	if err != nil {
+		return fmt.Errorf("%s is not a local issue ID", *todo.ID)
+	}
+
+	content, err := ioutil.ReadFile(tracker.issuePath(id))
+	if err != nil {
+		return err
+	}
+
+	issue, body, err := parseLocalIssue(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tracker.issuePath(id), err)
+	}
+
+	issue.State = "closed"
+	content, err = formatLocalIssue(issue, body)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(tracker.issuePath(id), content, 0644)
+}
+
+func (tracker LocalTracker) issueFile(todo Todo) string {
+	return tracker.issueURL("", todo)
+}
+
+func (tracker LocalTracker) getHost() string {
+	return "local"
+}
+
+func (tracker LocalTracker) projectURL(repo string) string {
+	return tracker.Dir
+}
+
+func (tracker LocalTracker) issueURL(repo string, todo Todo) string {
+	return path.Join(tracker.Dir, strings.TrimPrefix(*todo.ID, "#")+".md")
+}
+
+// getLocalTracker makes the local tracker configured in .snitch.yaml.
+// The issues directory is relative to the root of the project.
+func getLocalTracker(projectPath string, config *LocalConfig) (string, IssueAPI, error) {
+	dir := defaultLocalIssuesDir
+	if config != nil && len(config.Dir) > 0 {
+		dir = config.Dir
+	}
+
+	if !path.IsAbs(dir) {
+		dir = path.Join(projectPath, dir)
+	}
+
+	return dir, LocalTracker{Dir: dir}, nil
+}
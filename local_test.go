@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func git(t *testing.T, args ...string) {
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %s\n%s", strings.Join(args, " "), err, output)
+	}
+}
+
+// chdirTempGitRepo makes a git repo with the files committed and
+// changes into it. Returns the directory and the function that changes
+// back and removes it.
+func chdirTempGitRepo(t *testing.T, files map[string]string) (string, func()) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+
+	// The identity is restored, so it doesn't leak into the other tests
+	identity := map[string]string{
+		"GIT_AUTHOR_NAME":     "snitch",
+		"GIT_COMMITTER_NAME":  "snitch",
+		"GIT_AUTHOR_EMAIL":    "snitch@example.com",
+		"GIT_COMMITTER_EMAIL": "snitch@example.com",
+	}
+	previous := map[string]*string{}
+	for envar, value := range identity {
+		if old, ok := os.LookupEnv(envar); ok {
+			previous[envar] = &old
+		} else {
+			previous[envar] = nil
+		}
+		os.Setenv(envar, value)
+	}
+
+	cleanup := func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+
+		for envar, old := range previous {
+			if old != nil {
+				os.Setenv(envar, *old)
+			} else {
+				os.Unsetenv(envar)
+			}
+		}
+	}
+
+	git(t, "init", "-q")
+	for name, content := range files {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			log.Fatal(err)
+		}
+		git(t, "add", name)
+	}
+	git(t, "commit", "-q", "-m", "Initial commit")
+
+	return dir, cleanup
+}
+
+func TestLocalTracker_ReportAndPurge(t *testing.T) {
+	dir, cleanup := chdirTempGitRepo(t, map[string]string{
+		"main.go": "package main\n\n// TODO: Rewrite this in rust\nfunc main() {}\n",
+	})
+	defer cleanup()
+
+	project := Project{
+		Title:         &TitleConfig{},
+		Keywords:      []string{"TODO"},
+		BodySeparator: defaultBodySeparator,
+	}
+
+	repo, tracker, err := getLocalTracker(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acceptAll := func(todo Todo) bool { return true }
+	if err := reportSubcommand(context.Background(), project, tracker, repo, "", true, acceptAll); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile("main.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), "// TODO(#1): Rewrite this in rust") {
+		t.Fatalf("the TODO is not reported:\n%s", b)
+	}
+
+	issuePath := filepath.Join(dir, defaultLocalIssuesDir, "1.md")
+	issue, err := ioutil.ReadFile(issuePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The issue is committed together with the TODO
+	committed, err := runGit("", "show", "--name-only", "--format=", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := defaultLocalIssuesDir + "/1.md\nmain.go"; committed != want {
+		t.Errorf("got committed files %q, want %q", committed, want)
+	}
+
+	// The issue is still open, nothing to purge
+	if err := purgeSubcommand(context.Background(), project, tracker, repo, true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	closedIssue := strings.Replace(string(issue), "state: open", "state: closed", 1)
+	if err := ioutil.WriteFile(issuePath, []byte(closedIssue), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := purgeSubcommand(context.Background(), project, tracker, repo, true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = ioutil.ReadFile("main.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wantFileContent := "package main\n\nfunc main() {}\n"
+	if got := string(b); got != wantFileContent {
+		t.Errorf("got:\n%s\nwant:\n%s", got, wantFileContent)
+	}
+}
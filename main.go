@@ -2,8 +2,10 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"os/user"
 	"path"
 	"path/filepath"
@@ -38,34 +40,188 @@ func yOrN(question string, alwaysYes bool) (bool, error) {
 	return true, err
 }
 
-func listSubcommand(project Project, filter func(todo Todo) bool) error {
+// StatusFilter is `list --status`. It annotates the reported todos
+// with their issues and keeps only the todos whose issues are in one
+// of States. Empty States keeps all of the todos.
+type StatusFilter struct {
+	Resolver IssueResolver
+	Cache    *IssueCache
+	States   []string
+}
+
+// The statuses of the todos that have no issue to ask the tracker about
+// or whose tracker couldn't tell
+const (
+	statusUnreported = "unreported"
+	statusPending    = "pending"
+	statusNotFound   = "not found"
+	statusError      = "error"
+)
+
+// retrieve asks the tracker about all of the reported todos at once.
+// Returns the status of each todo, the issues found by ID and the
+// errors of the todos whose trackers failed. A failure doesn't stop
+// the rest of the todos from being listed, only the cancellation does.
+func (status StatusFilter) retrieve(ctx context.Context, todos []*Todo) ([]string, map[string]Issue, map[string]error, error) {
+	reportedTodos := []Todo{}
+	for _, todo := range todos {
+		if todo.ID != nil && !isPendingID(*todo.ID) {
+			reportedTodos = append(reportedTodos, *todo)
+		}
+	}
+
+	errs := map[string]error{}
+	issues, err := status.Resolver.collectIssues(ctx, status.Cache, reportedTodos, func(ids []string, err error) error {
+		if ctx.Err() != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			errs[id] = err
+		}
+		return nil
+	})
+	if cerr := status.Cache.save(); cerr != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] Couldn't save the cache: %s\n", cerr)
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Couldn't retrieve the statuses of the issues\n%w", err)
+	}
+
+	statuses := make([]string, len(todos))
+	for i, todo := range todos {
+		switch {
+		case todo.ID == nil:
+			statuses[i] = statusUnreported
+		case isPendingID(*todo.ID):
+			statuses[i] = statusPending
+		default:
+			if issue, ok := issues[*todo.ID]; ok {
+				statuses[i] = issue.State
+			} else if _, failed := errs[*todo.ID]; failed {
+				statuses[i] = statusError
+			} else {
+				statuses[i] = statusNotFound
+			}
+		}
+	}
+
+	return statuses, issues, errs, nil
+}
+
+// keeps tells whether the todo is listed. The todos whose status is
+// unknown are listed with the error, so they don't silently go missing.
+func (status StatusFilter) keeps(state string) bool {
+	if len(status.States) == 0 || state == statusError {
+		return true
+	}
+
+	for _, wanted := range status.States {
+		if state == wanted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// statusAnnotation formats the status of the todo for `list --status`
+// like [open; assigned to alice; labels: bug, ui]
+func statusAnnotation(state string, issue Issue) string {
+	parts := []string{state}
+	if len(issue.Assignees) > 0 {
+		parts = append(parts, "assigned to "+strings.Join(issue.Assignees, ", "))
+	}
+	if len(issue.Labels) > 0 {
+		parts = append(parts, "labels: "+strings.Join(issue.Labels, ", "))
+	}
+
+	return "[" + strings.Join(parts, "; ") + "]"
+}
+
+// listSubcommand prints the todos. The status may be nil, then the
+// tracker is not asked about anything.
+func listSubcommand(ctx context.Context, project Project, filter func(todo Todo) bool, withBlame bool, sortBy string, status *StatusFilter) error {
 	todosToList := []*Todo{}
 
 	err := project.WalkTodosOfDir(".", func(todo Todo) error {
-		if filter(todo) {
-			todosToList = append(todosToList, &todo)
+		if !filter(todo) {
+			return nil
 		}
+
+		if withBlame {
+			blame, err := todo.GitBlame()
+			if err != nil {
+				return err
+			}
+			todo.Blame = blame
+		}
+
+		todosToList = append(todosToList, &todo)
 		return nil
 	})
 	if err != nil {
 		return err
 	}
 
-	sort.Slice(todosToList, func(i, j int) bool {
-		return todosToList[i].Urgency > todosToList[j].Urgency
-	})
+	annotations := map[*Todo]string{}
+	if status != nil {
+		statuses, issues, errs, err := status.retrieve(ctx, todosToList)
+		if err != nil {
+			return err
+		}
+
+		keptTodos := []*Todo{}
+		for i, todo := range todosToList {
+			if !status.keeps(statuses[i]) {
+				continue
+			}
+
+			switch {
+			case statuses[i] == statusError:
+				annotations[todo] = fmt.Sprintf("[%s: %s]", statusError, errs[*todo.ID])
+			case todo.ID != nil:
+				annotations[todo] = statusAnnotation(statuses[i], issues[*todo.ID])
+			default:
+				annotations[todo] = statusAnnotation(statuses[i], Issue{})
+			}
+			keptTodos = append(keptTodos, todo)
+		}
+		todosToList = keptTodos
+	}
+
+	switch sortBy {
+	case "urgency":
+		sort.Slice(todosToList, func(i, j int) bool {
+			return todosToList[i].Urgency > todosToList[j].Urgency
+		})
+	case "age":
+		// The oldest todos go first
+		sort.SliceStable(todosToList, func(i, j int) bool {
+			return todosToList[i].Blame.Time.Before(todosToList[j].Blame.Time)
+		})
+	default:
+		return fmt.Errorf("Unknown sort order `%s'. Expected `urgency' or `age'", sortBy)
+	}
 
 	for _, todo := range todosToList {
-		fmt.Println(todo.LogString())
+		line := todo.LogString()
+		if annotation, ok := annotations[todo]; ok {
+			line += " " + annotation
+		}
+		if todo.Blame != nil {
+			line += fmt.Sprintf(" [%s]", todo.Blame)
+		}
+		fmt.Println(line)
 	}
 
 	return nil
 }
 
-func reportSubcommand(project Project, creds IssueAPI, repo string, prependBody string, alwaysYes bool) error {
+func reportSubcommand(ctx context.Context, project Project, creds IssueAPI, repo string, prependBody string, alwaysYes bool, filter func(todo Todo) bool) error {
 	todosToReport := []*Todo{}
 	err := project.WalkTodosOfDir(".", func(todo Todo) error {
-		if todo.ID != nil {
+		if todo.ID != nil || !filter(todo) {
 			return nil
 		}
 
@@ -75,6 +231,19 @@ func reportSubcommand(project Project, creds IssueAPI, repo string, prependBody
 			fmt.Printf("  %s\n", bodyLine)
 		}
 
+		if len(project.Assignees) > 0 {
+			blame, err := todo.GitBlame()
+			if err != nil {
+				return err
+			}
+			todo.Blame = blame
+			todo.Assignee = project.AssigneeOf(blame)
+
+			if len(todo.Assignee) > 0 {
+				fmt.Printf("Assignee: %s (%s)\n", todo.Assignee, blame)
+			}
+		}
+
 		yes, err := yOrN("Do you want to report this? ", alwaysYes)
 
 		if err != nil {
@@ -89,48 +258,198 @@ func reportSubcommand(project Project, creds IssueAPI, repo string, prependBody
 	})
 
 	for _, todo := range todosToReport {
-		reportedTodo, err := todo.Report(creds, repo,
-			prependBody+"\n\n"+strings.Join(todo.Body, "\n\n"))
+		if err := reportTodo(ctx, *todo, creds, repo, prependBody); err != nil {
+			return err
+		}
+	}
 
-		if err != nil {
+	return err
+}
+
+// reportTodo reports a single todo. Once the issue is created the todo
+// is updated and committed even if snitch is interrupted.
+func reportTodo(ctx context.Context, todo Todo, creds IssueAPI, repo string, prependBody string) error {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	reportedTodo, err := todo.Report(ctx, creds, repo,
+		withLocation(creds, prependBody+"\n\n"+strings.Join(todo.Body, "\n\n"), todo))
+
+	if err != nil {
+		return fmt.Errorf("Couldn't report %s\n%w", todo.LogString(), err)
+	}
+
+	fmt.Printf("[REPORTED] %v\n", reportedTodo.LogString())
+
+	err = reportedTodo.Update()
+	if err != nil {
+		return err
+	}
+
+	if err := stageIssueFile(creds, reportedTodo); err != nil {
+		return err
+	}
+
+	return reportedTodo.GitCommit("Add")
+}
+
+// stageIssueFile adds the new file of the issue of the todo to the git
+// index, so it's committed together with the todo. The issues kept
+// outside of the repo or ignored by it are left alone.
+func stageIssueFile(creds IssueAPI, todo Todo) error {
+	filer, ok := creds.(IssueFiler)
+	if !ok {
+		return nil
+	}
+
+	untracked, err := runGit("", "ls-files", "--others", "--exclude-standard", "--", filer.issueFile(todo))
+	if err != nil || len(untracked) == 0 {
+		return nil
+	}
+
+	return LogCommand(exec.Command("git", "add", "--", filer.issueFile(todo))).Run()
+}
+
+// flushSubcommand creates the issues reported with --offline and
+// replaces their placeholder IDs in the code with the real ones
+func flushSubcommand(ctx context.Context, project Project, creds IssueAPI, repo string, queue *OfflineQueue) error {
+	pendingTodos := map[string][]Todo{}
+	err := project.WalkTodosOfDir(".", func(todo Todo) error {
+		if todo.ID != nil && isPendingID(*todo.ID) {
+			pendingTodos[*todo.ID] = append(pendingTodos[*todo.ID], todo)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range append([]OfflineIssue{}, queue.Issues...) {
+		todos := pendingTodos[issue.ID]
+		if len(todos) == 0 {
+			fmt.Printf("[WARN] %s is not in the code anymore. Dropping it\n", issue.ID)
+			if err := queue.remove(issue.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := flushIssue(ctx, issue, todos, creds, repo, queue); err != nil {
 			return err
 		}
+	}
 
-		fmt.Printf("[REPORTED] %v\n", reportedTodo.LogString())
+	return nil
+}
 
-		err = reportedTodo.Update()
-		if err != nil {
+// flushIssue creates a single queued issue. Once it's created the
+// placeholders are replaced and committed even if snitch is
+// interrupted.
+func flushIssue(ctx context.Context, issue OfflineIssue, todos []Todo, creds IssueAPI, repo string, queue *OfflineQueue) error {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	body := withLocation(creds, issue.Body, todos[0])
+	reportedTodo, err := Todo{Title: issue.Title, Assignee: issue.Assignee}.Report(ctx, creds, repo, body)
+	if err != nil {
+		return fmt.Errorf("Couldn't report %s\n%w", issue.ID, err)
+	}
+
+	for _, todo := range todos {
+		todo.ID = reportedTodo.ID
+		fmt.Printf("[REPORTED] %v\n", todo.LogString())
+
+		if err := todo.Update(); err != nil {
 			return err
 		}
 
-		err = reportedTodo.GitCommit("Add")
-		if err != nil {
+		if err := LogCommand(exec.Command("git", "add", todo.Filename)).Run(); err != nil {
 			return err
 		}
 	}
 
-	return err
+	if err := queue.remove(issue.ID); err != nil {
+		return err
+	}
+
+	if err := stageIssueFile(creds, reportedTodo); err != nil {
+		return err
+	}
+
+	return todos[0].GitCommit("Report")
 }
 
-func purgeSubcommand(project Project, creds IssueAPI, repo string, alwaysYes bool) error {
-	todosToRemove := []*Todo{}
+func purgeSubcommand(ctx context.Context, project Project, creds IssueAPI, repo string, alwaysYes bool, cache *IssueCache) error {
+	reportedTodos := []Todo{}
 	err := project.WalkTodosOfDir(".", func(todo Todo) error {
 		if todo.ID == nil {
 			return nil
 		}
 
-		status, err := todo.RetrieveStatus(creds, repo)
-		if err != nil {
-			return err
+		if isPendingID(*todo.ID) {
+			fmt.Printf("[PENDING] %v\n", todo.LogString())
+			return nil
 		}
-		if status != "closed" {
+
+		reportedTodos = append(reportedTodos, todo)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// A closed issue may have been reopened since it was cached
+	if cache != nil {
+		cache.RevalidateClosed = true
+	}
+
+	// All of the statuses are retrieved at once so the trackers with
+	// the batched APIs need only a handful of requests
+	resolver := newIssueResolver(project, creds, repo)
+	issues, err := resolver.getIssues(ctx, cache, reportedTodos)
+	if cerr := cache.save(); cerr != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] Couldn't save the cache: %s\n", cerr)
+	}
+	if err != nil {
+		return fmt.Errorf("Couldn't retrieve the statuses of the issues\n%w", err)
+	}
+
+	// The private repos answer 404 to the tokens that can't see them,
+	// so when none of the issues exist it's most likely the token
+	if len(reportedTodos) > 0 && len(issues) == 0 {
+		return fmt.Errorf("None of the issues of the %d reported TODOs have been found on %s. "+
+			"If %s is private, make sure the token has access to it (the `repo' scope on GitHub)",
+			len(reportedTodos), creds.getHost(), repo)
+	}
+
+	todosToRemove := []*Todo{}
+	for i := range reportedTodos {
+		todo := &reportedTodos[i]
+
+		issue, ok := issues[*todo.ID]
+		if !ok {
+			// Deleted or transferred issues shouldn't stop the rest
+			fmt.Printf("[NOT FOUND] %v\n", todo.LogString())
+			fmt.Printf("Issue link: %s\n", resolver.issueURL(*todo))
+			continue
+		}
+
+		if issue.State != issueClosed {
 			fmt.Printf("[OPEN] %v\n", todo.LogString())
-			return nil
+			continue
 		}
 
 		fmt.Printf("[CLOSED] %v\n", todo.LogString())
-		fmt.Printf("Issue link: https://%s/%s/issues/%s\n",
-			creds.getHost(), repo, (*todo.ID)[1:])
+		fmt.Printf("Issue link: %s\n", resolver.issueURL(*todo))
 
 		yes, err := yOrN("This issue is closed. Do you want to remove the TODO?", alwaysYes)
 
@@ -139,13 +458,8 @@ func purgeSubcommand(project Project, creds IssueAPI, repo string, alwaysYes boo
 		}
 
 		if yes {
-			todosToRemove = append(todosToRemove, &todo)
+			todosToRemove = append(todosToRemove, todo)
 		}
-
-		return err
-	})
-	if err != nil {
-		return err
 	}
 
 	sort.Slice(todosToRemove, func(i, j int) bool {
@@ -157,14 +471,7 @@ func purgeSubcommand(project Project, creds IssueAPI, repo string, alwaysYes boo
 	})
 
 	for _, todo := range todosToRemove {
-		err = todo.Remove()
-		if err != nil {
-			return err
-		}
-		fmt.Printf("[REMOVED] %v\n", todo)
-
-		err = todo.GitCommit("Remove")
-		if err != nil {
+		if err := removeTodo(ctx, *todo); err != nil {
 			return err
 		}
 	}
@@ -172,12 +479,43 @@ func purgeSubcommand(project Project, creds IssueAPI, repo string, alwaysYes boo
 	return err
 }
 
+// removeTodo removes a single todo and commits the removal without
+// being interrupted in between
+func removeTodo(ctx context.Context, todo Todo) error {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := todo.Remove()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[REMOVED] %v\n", todo)
+
+	return todo.GitCommit("Remove")
+}
+
 func usage() {
 	// FIXME(#9): implement a map for options instead of println'ing them all there
 	fmt.Printf("snitch [opt]\n" +
-		"\tlist [--unreported] [--reported] [--y] [--remote]: lists all todos of a dir recursively\n" +
-		"\treport [--prepend-body <issue-body>] [--y] [--remote]: reports all todos of a dir recursively \n\t\tas GitHub issues\n" +
-		"\tpurge [--remote]: removes all of the reported TODOs that refer to closed issues\n")
+		"\tlist [--unreported] [--reported] [--y] [--remote] [--since <ref>] [--staged] [--blame] [--sort <urgency|age>] [--status] [--open] [--closed] [--timeout <duration>] [--cache-ttl <duration>] [--no-cache]: lists all todos of a dir recursively\n" +
+		"\t\t--blame shows the author, the commit and the age of each todo, --sort age implies --blame\n" +
+		"\t\t(works outside of git repos too, respecting .gitignore and .hgignore)\n" +
+		"\t\t--status shows the state, the assignees and the labels of the issue of each reported todo\n" +
+		"\t\t--open and --closed only list the todos whose issues are open or closed, they imply --status\n" +
+		"\treport [--prepend-body <issue-body>] [--y] [--remote] [--since <ref>] [--staged] [--timeout <duration>] [--offline] [--flush]: reports all todos of a dir recursively \n\t\tas GitHub issues\n" +
+		"\t\t--since <ref> only considers the todos on the lines added since the git ref\n" +
+		"\t\t--staged only considers the todos on the lines added to the git index\n" +
+		"\t\t--timeout <duration> limits every request to the tracker, 30s by default\n" +
+		"\t\t--offline queues the issues and puts placeholder IDs into the code, --flush creates the queued issues\n" +
+		"\tpurge [--remote] [--timeout <duration>] [--cache-ttl <duration>] [--no-cache]: removes all of the reported TODOs that refer to closed issues\n" +
+		"\t\t--cache-ttl <duration> trusts the cached issue states for that long, 5m by default\n" +
+		"\trelocate [--comment] [--y] [--remote] [--timeout <duration>]: updates the location of the moved TODOs in their issues\n" +
+		"\t\t--comment also comments on the issues with the new location\n" +
+		"\tclose <id>: closes the issue of the local or git tracker\n")
 }
 
 func locateDotGit(dir string) (string, error) {
@@ -248,17 +586,52 @@ func getRemote(params map[string]string) string {
 	return "origin"
 }
 
-func getRepo(directory string, remote string) (string, IssueAPI, error) {
-	credentials := getCredentials()
-	if len(credentials) == 0 {
-		return "", nil, fmt.Errorf("No credentials have been found. Read https://github.com/tsoding/snitch#credentials")
+// matchRepo extracts the repo from the remote URL if the remote is
+// hosted by the tracker
+func matchRepo(creds IssueAPI, urlString string) (string, bool) {
+	urlString = strings.TrimSuffix(urlString, ".git")
+
+	if matcher, ok := creds.(RemoteMatcher); ok {
+		return matcher.matchRemote(urlString)
+	}
+
+	return matchHostRepo(creds.getHost(), urlString, `[-.\w]+/[-.\w]+`)
+}
+
+// matchHostRepo extracts the repo matching repoPattern from the remote
+// URL of the host
+func matchHostRepo(host string, urlString string, repoPattern string) (string, bool) {
+	// The host must not be a suffix of another host, otherwise
+	// github.com would steal the remotes of ghe.github.com
+	s := "(?:^|[@/])" + regexp.QuoteMeta(host) + "(?::\\d+)?[:/](" + repoPattern + ")/?$"
+	hostRegex := regexp.MustCompile(s)
+
+	groups := hostRegex.FindStringSubmatch(urlString)
+	if groups == nil {
+		return "", false
 	}
 
+	repo := groups[1]
+	if !isSafeRepo(repo) {
+		return "", false
+	}
+
+	return repo, true
+}
+
+func getRepo(directory string, remote string) (string, IssueAPI, error) {
+	// Reporting and purging commit the changes, so they only make
+	// sense inside of a git repo
 	dotGit, err := locateDotGit(directory)
 	if err != nil {
 		return "", nil, err
 	}
 
+	credentials := getCredentials()
+	if len(credentials) == 0 {
+		return "", nil, fmt.Errorf("No credentials have been found. Read https://github.com/tsoding/snitch#credentials")
+	}
+
 	configPath := path.Join(dotGit, "config")
 
 	cfg, err := ini.Load(configPath)
@@ -293,13 +666,8 @@ func getRepo(directory string, remote string) (string, IssueAPI, error) {
 	}
 
 	for _, creds := range credentials {
-		s := creds.getHost() + "[:/]([-\\.\\w]+)\\/([-\\.\\w]+)"
-		hostRegex := regexp.MustCompile(s)
-
-		groups := hostRegex.FindStringSubmatch(strings.TrimSuffix(urlString, ".git"))
-
-		if groups != nil {
-			return groups[1] + "/" + groups[2], creds, nil
+		if repo, ok := matchRepo(creds, urlString); ok {
+			return repo, creds, nil
 		}
 	}
 
@@ -310,6 +678,41 @@ func getRepo(directory string, remote string) (string, IssueAPI, error) {
 	return "", nil, fmt.Errorf("%s does not match any of the hosts", urlString)
 }
 
+// getTracker finds the issue tracker of the project. Unless
+// .snitch.yaml says otherwise the tracker is the host of the remote.
+func getTracker(project Project, params map[string]string) (string, IssueAPI, error) {
+	// Reporting and purging commit the changes no matter where the
+	// issues live
+	if _, err := locateDotGit("."); err != nil {
+		return "", nil, err
+	}
+
+	switch project.Tracker {
+	case "":
+		repo, creds, err := getRepo(".", getRemote(params))
+		if configurer, ok := creds.(ProjectConfigurer); ok {
+			repo, creds = configurer.configure(project, repo)
+		}
+		return repo, creds, err
+	case "jira":
+		return getJiraTracker(project.Jira)
+	case "local":
+		projectPath, err := locateProject(".")
+		if err != nil {
+			return "", nil, err
+		}
+		return getLocalTracker(projectPath, project.Local)
+	case "git":
+		return gitRefIssuesNamespace, GitRefTracker{}, nil
+	case "redmine":
+		return getRedmineTracker(project.Redmine)
+	case "generic":
+		return getGenericTracker(project.Generic)
+	default:
+		return "", nil, fmt.Errorf("Unknown tracker `%s' in .snitch.yaml", project.Tracker)
+	}
+}
+
 func parseParams(args []string) (map[string]string, error) {
 	currentParam := ""
 	result := map[string]string{}
@@ -360,13 +763,54 @@ func checkParams(params map[string]string, allowedParams []string) error {
 	return nil
 }
 
+// projectMarkers identify the root of a project that is not a git repo
+var projectMarkers = []string{".snitch.yaml", ".snitch.yml", ".hg"}
+
 func locateProject(directory string) (string, error) {
 	dotGit, err := locateDotGit(directory)
+	if err == nil {
+		return filepath.Dir(dotGit), nil
+	}
+
+	absDir, err := filepath.Abs(directory)
 	if err != nil {
 		return "", err
 	}
 
-	return filepath.Dir(dotGit), nil
+	for dir, rooted := absDir, ""; dir != rooted; dir, rooted = filepath.Dir(dir), dir {
+		for _, marker := range projectMarkers {
+			if _, err := os.Stat(path.Join(dir, marker)); err == nil {
+				return dir, nil
+			}
+		}
+	}
+
+	return absDir, nil
+}
+
+// changedLinesFilter makes a filter that accepts only the todos
+// located on the lines added since --since <ref> and/or to the index
+// with --staged. Without those flags it accepts everything.
+func changedLinesFilter(params map[string]string) (func(todo Todo) bool, error) {
+	ref, since := params["since"]
+	_, staged := params["staged"]
+
+	if !since && !staged {
+		return func(todo Todo) bool {
+			return true
+		}, nil
+	}
+
+	if since && len(ref) == 0 {
+		return nil, fmt.Errorf("--since requires a git ref")
+	}
+
+	changedLines, err := GitChangedLines(ref, staged)
+	if err != nil {
+		return nil, err
+	}
+
+	return changedLines.Contains, nil
 }
 
 func exitOnError(err error) {
@@ -379,11 +823,13 @@ func exitOnError(err error) {
 func getCredentials() []IssueAPI {
 	creds := []IssueAPI{}
 
-	if github, err := getGithubCredentials(); err == nil {
-		creds = append(creds, github)
-	}
+	creds = getGithubCredentials(creds)
 	creds = getGitlabCredentials(creds)
 	creds = getGiteaCredentials(creds)
+	creds = getBitbucketCredentials(creds)
+	creds = getBitbucketServerCredentials(creds)
+	creds = getAzureCredentials(creds)
+	creds = getSourcehutCredentials(creds)
 	return creds
 }
 
@@ -406,12 +852,50 @@ func main() {
 			params, err := parseParams(os.Args[2:])
 			exitOnError(err)
 
-			err = checkParams(params, []string{"unreported", "reported", "remote"})
+			err = checkParams(params, []string{"unreported", "reported", "remote", "since", "staged", "blame", "sort", "status", "open", "closed", "timeout", "cache-ttl", "no-cache"})
 			exitOnError(err)
 			_, unreported := params["unreported"]
 			_, reported := params["reported"]
+			_, withBlame := params["blame"]
+
+			var status *StatusFilter
+			_, withStatus := params["status"]
+			for _, state := range []string{issueOpen, issueClosed} {
+				if _, ok := params[state]; ok {
+					if status == nil {
+						status = &StatusFilter{}
+					}
+					status.States = append(status.States, state)
+				}
+			}
+			if withStatus && status == nil {
+				status = &StatusFilter{}
+			}
+
+			sortBy := params["sort"]
+			if len(sortBy) == 0 {
+				sortBy = "urgency"
+			}
+			withBlame = withBlame || sortBy == "age"
+
+			changed, err := changedLinesFilter(params)
+			exitOnError(err)
+
+			ctx := context.Background()
+			if status != nil {
+				exitOnError(setHTTPTimeout(params))
+
+				status.Cache, err = getIssueCache(params)
+				exitOnError(err)
 
-			err = listSubcommand(*project, func(todo Todo) bool {
+				repo, creds, err := getTracker(*project, params)
+				exitOnError(err)
+				status.Resolver = newIssueResolver(*project, creds, repo)
+
+				ctx = interruptContext()
+			}
+
+			err = listSubcommand(ctx, *project, func(todo Todo) bool {
 				filter := reported == unreported
 
 				if unreported {
@@ -422,14 +906,14 @@ func main() {
 					filter = filter || todo.ID != nil
 				}
 
-				return filter
-			})
+				return filter && changed(todo)
+			}, withBlame, sortBy, status)
 			exitOnError(err)
 		case "report":
 			params, err := parseParams(os.Args[2:])
 			exitOnError(err)
 
-			err = checkParams(params, []string{"prepend-body", "y", "remote"})
+			err = checkParams(params, []string{"prepend-body", "y", "remote", "since", "staged", "timeout", "offline", "flush"})
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				usage()
@@ -442,13 +926,45 @@ func main() {
 			}
 
 			_, alwaysYes := params["y"]
+			_, offline := params["offline"]
+			_, flush := params["flush"]
 
-			repo, creds, err := getRepo(".", getRemote(params))
+			if offline && flush {
+				exitOnError(fmt.Errorf("--offline and --flush can't be used together"))
+			}
+
+			changed, err := changedLinesFilter(params)
+			exitOnError(err)
+
+			exitOnError(setHTTPTimeout(params))
+
+			queuePath, err := offlineQueuePath()
 			exitOnError(err)
 
-			fmt.Printf("Detected project: https://%s/%s\n", creds.getHost(), repo)
+			queue, err := loadOfflineQueue(queuePath)
+			exitOnError(err)
+
+			var repo string
+			var creds IssueAPI
+			if offline {
+				creds = OfflineTracker{Queue: queue}
+			} else {
+				repo, creds, err = getTracker(*project, params)
+				exitOnError(err)
+			}
+
+			fmt.Printf("Detected project: %s\n", projectURL(creds, repo))
 
-			if err = reportSubcommand(*project, creds, repo, prependBody, alwaysYes); err != nil {
+			if flush {
+				exitOnError(flushSubcommand(interruptContext(), *project, creds, repo, queue))
+				return
+			}
+
+			if !offline && len(queue.Issues) > 0 {
+				fmt.Printf("[WARN] %d issues were reported offline. Run `snitch report --flush` to create them\n", len(queue.Issues))
+			}
+
+			if err = reportSubcommand(interruptContext(), *project, creds, repo, prependBody, alwaysYes, changed); err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
 			}
@@ -456,22 +972,67 @@ func main() {
 			params, err := parseParams(os.Args[2:])
 			exitOnError(err)
 
-			err = checkParams(params, []string{"y", "remote"})
+			err = checkParams(params, []string{"y", "remote", "timeout", "cache-ttl", "no-cache"})
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				usage()
 				os.Exit(1)
 			}
 
-			repo, creds, err := getRepo(".", getRemote(params))
+			exitOnError(setHTTPTimeout(params))
+
+			cache, err := getIssueCache(params)
+			exitOnError(err)
+
+			repo, creds, err := getTracker(*project, params)
 			exitOnError(err)
 
 			_, alwaysYes := params["y"]
 
-			if err = purgeSubcommand(*project, creds, repo, alwaysYes); err != nil {
+			if err = purgeSubcommand(interruptContext(), *project, creds, repo, alwaysYes, cache); err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
 			}
+		case "relocate":
+			params, err := parseParams(os.Args[2:])
+			exitOnError(err)
+
+			err = checkParams(params, []string{"comment", "y", "remote", "timeout"})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				usage()
+				os.Exit(1)
+			}
+
+			exitOnError(setHTTPTimeout(params))
+
+			repo, creds, err := getTracker(*project, params)
+			exitOnError(err)
+
+			_, comment := params["comment"]
+			_, alwaysYes := params["y"]
+
+			if err = relocateSubcommand(interruptContext(), *project, creds, repo, comment, alwaysYes); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		case "close":
+			if len(os.Args) != 3 {
+				usage()
+				os.Exit(1)
+			}
+
+			_, creds, err := getTracker(*project, map[string]string{})
+			exitOnError(err)
+
+			closer, ok := creds.(IssueCloser)
+			if !ok {
+				exitOnError(fmt.Errorf("Issues of %s are closed through its own interface", creds.getHost()))
+			}
+
+			id := "#" + strings.TrimPrefix(os.Args[2], "#")
+			exitOnError(closer.CloseIssue(Todo{ID: &id}))
+			fmt.Printf("[CLOSED] %s\n", id)
 		default:
 			fmt.Fprintf(os.Stderr, "`%s` unknown command\n", os.Args[1])
 			os.Exit(1)
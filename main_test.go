@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchRepo(t *testing.T) {
+	credentials := []IssueAPI{
+		GithubCredentials{Host: "github.com"},
+		GithubCredentials{Host: "ghe.github.com"},
+		GitlabCredentials{Host: "gitlab.example.com"},
+	}
+
+	tests := []struct {
+		url  string
+		host string
+		repo string
+	}{
+		{"git@github.com:tsoding/snitch.git", "github.com", "tsoding/snitch"},
+		{"https://github.com/tsoding/snitch", "github.com", "tsoding/snitch"},
+		{"git@ghe.github.com:tsoding/snitch.git", "ghe.github.com", "tsoding/snitch"},
+		{"https://ghe.github.com/tsoding/snitch", "ghe.github.com", "tsoding/snitch"},
+		{"ssh://git@gitlab.example.com:2222/tsoding/snitch.git", "gitlab.example.com", "tsoding/snitch"},
+		{"https://gitlab.example.com.evil.com/tsoding/snitch", "", ""},
+		{"git@gitlab.example.com:group/sub/project.git", "gitlab.example.com", "group/sub/project"},
+		{"https://gitlab.example.com/group/sub/subsub/project", "gitlab.example.com", "group/sub/subsub/project"},
+		{"https://github.com/tsoding/snitch/extra", "", ""},
+		{"git@gitlab.example.com:group/../project.git", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			host, repo := "", ""
+			for _, creds := range credentials {
+				if r, ok := matchRepo(creds, tt.url); ok {
+					host, repo = creds.getHost(), r
+					break
+				}
+			}
+
+			if host != tt.host || repo != tt.repo {
+				t.Errorf("got %s %s, want %s %s", host, repo, tt.host, tt.repo)
+			}
+		})
+	}
+}
+
+func TestPurgeSubcommand_NothingFound(t *testing.T) {
+	content := "package main\n\n// TODO(#1): Rewrite this in Rust\n// TODO(#2): And then in Zig\n"
+	_, cleanup := chdirTempGitRepo(t, map[string]string{
+		"main.go": content,
+	})
+	defer cleanup()
+
+	// Like GitHub answers to the tokens that can't see the private repo
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	project := Project{
+		Title:    &TitleConfig{},
+		Keywords: []string{"TODO"},
+	}
+	creds := GiteaCredentials{Host: "gitea.example.com", Instance: Instance{BaseURL: server.URL}}
+
+	err := purgeSubcommand(context.Background(), project, creds, "alice/snitch", true, nil)
+	if err == nil || !strings.Contains(err.Error(), "make sure the token has access") {
+		t.Fatalf("got %v, want the hint about the token", err)
+	}
+
+	b, err := ioutil.ReadFile("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Errorf("the TODOs have been changed:\n%s", b)
+	}
+}
+
+func TestStatusFilter_Retrieve(t *testing.T) {
+	dir, cleanup := chdirTempGitRepo(t, map[string]string{
+		"main.go": "package main\n",
+	})
+	defer cleanup()
+
+	repo, tracker, err := getLocalTracker(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuesDir := filepath.Join(dir, defaultLocalIssuesDir)
+	if err := os.MkdirAll(issuesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	issues := map[string]string{
+		"1.md": "---\nid: 1\ntitle: foo\nstate: closed\nassignee: alice\nlabels: [bug, ui]\n---\n",
+		"2.md": "---\nid: 2\ntitle: bar\nstate: open\n---\n",
+	}
+	for name, content := range issues {
+		if err := ioutil.WriteFile(filepath.Join(issuesDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id := func(id string) *string { return &id }
+	todos := []*Todo{
+		{ID: id("#1")},
+		{ID: id("#2")},
+		{ID: id("#3")},
+		{ID: id("pending-1")},
+		{},
+	}
+
+	status := StatusFilter{Resolver: IssueResolver{Creds: tracker, Repo: repo}}
+	statuses, found, errs, err := status.retrieve(context.Background(), todos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantStatuses := []string{issueClosed, issueOpen, statusNotFound, statusPending, statusUnreported}
+	if !reflect.DeepEqual(statuses, wantStatuses) || len(errs) != 0 {
+		t.Errorf("got %v %v, want %v", statuses, errs, wantStatuses)
+	}
+
+	wantAnnotation := "[closed; assigned to alice; labels: bug, ui]"
+	if got := statusAnnotation(statuses[0], found["#1"]); got != wantAnnotation {
+		t.Errorf("got %s, want %s", got, wantAnnotation)
+	}
+
+	status.States = []string{issueClosed}
+	for i, state := range statuses {
+		if got := status.keeps(state); got != (i == 0) {
+			t.Errorf("keeps(%s) = %v", state, got)
+		}
+	}
+}
+
+func TestStatusFilter_RetrieveErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/v1/repos/alice/private/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"number": 1, "state": "open"}`))
+	}))
+	defer server.Close()
+
+	creds := GiteaCredentials{Host: "gitea.example.com", Instance: Instance{BaseURL: server.URL}}
+	status := StatusFilter{
+		Resolver: IssueResolver{Creds: creds, Repo: "alice/snitch", CrossRepo: true},
+		States:   []string{issueClosed},
+	}
+
+	todos := []*Todo{
+		{ID: stringPtr("#1")},
+		{ID: stringPtr("alice/private#1")},
+	}
+
+	// The forbidden repo doesn't stop the rest of the todos
+	statuses, _, errs, err := status.retrieve(context.Background(), todos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantStatuses := []string{issueOpen, statusError}
+	if !reflect.DeepEqual(statuses, wantStatuses) {
+		t.Errorf("got %v, want %v", statuses, wantStatuses)
+	}
+
+	if !errors.Is(errs["alice/private#1"], ErrForbidden) {
+		t.Errorf("got %v, want %v", errs["alice/private#1"], ErrForbidden)
+	}
+
+	// Even with --closed the todos whose status is unknown are listed
+	if !status.keeps(statusError) {
+		t.Errorf("the todo whose status is unknown is filtered out")
+	}
+}
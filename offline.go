@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pendingIDPrefix starts the placeholder IDs of the TODOs reported
+// with --offline, like TODO(pending-3). They are replaced with the real
+// IDs by --flush.
+const pendingIDPrefix = "pending-"
+
+// OfflineIssue is an issue reported with --offline waiting to be
+// created by --flush
+type OfflineIssue struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	Assignee string `json:"assignee,omitempty"`
+	Queued   string `json:"queued"`
+}
+
+// OfflineQueue is the list of the issues reported with --offline. It
+// lives inside of .git so it's never committed by accident.
+type OfflineQueue struct {
+	// Next is the number of the next placeholder ID. It's never
+	// reused so the placeholders left in the code by mistake can't
+	// be confused with the new ones.
+	Next   int            `json:"next"`
+	Issues []OfflineIssue `json:"issues"`
+
+	path string
+}
+
+func isPendingID(id string) bool {
+	return strings.HasPrefix(id, pendingIDPrefix)
+}
+
+// offlineQueuePath is .git/snitch/offline.json of the current repo
+func offlineQueuePath() (string, error) {
+	dotGit, err := locateDotGit(".")
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dotGit, "snitch", "offline.json"), nil
+}
+
+// loadOfflineQueue reads the queue. A missing file is an empty queue.
+func loadOfflineQueue(queuePath string) (*OfflineQueue, error) {
+	queue := &OfflineQueue{
+		Next:   1,
+		Issues: []OfflineIssue{},
+		path:   queuePath,
+	}
+
+	content, err := ioutil.ReadFile(queuePath)
+	if os.IsNotExist(err) {
+		return queue, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, queue); err != nil {
+		return nil, fmt.Errorf("%s: %w", queuePath, err)
+	}
+
+	return queue, nil
+}
+
+// save replaces the queue file atomically
+func (queue *OfflineQueue) save() error {
+	content, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(queue.path, append(content, '\n'))
+}
+
+// push queues the issue and returns its placeholder ID
+func (queue *OfflineQueue) push(todo Todo, body string) (string, error) {
+	issue := OfflineIssue{
+		ID:       pendingIDPrefix + strconv.Itoa(queue.Next),
+		Title:    todo.Title,
+		Body:     body,
+		Assignee: todo.Assignee,
+		Queued:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	queue.Next++
+	queue.Issues = append(queue.Issues, issue)
+
+	if err := queue.save(); err != nil {
+		queue.Next--
+		queue.Issues = queue.Issues[:len(queue.Issues)-1]
+		return "", err
+	}
+
+	return issue.ID, nil
+}
+
+// remove drops the flushed issue from the queue
+func (queue *OfflineQueue) remove(id string) error {
+	issues := []OfflineIssue{}
+	for _, issue := range queue.Issues {
+		if issue.ID != id {
+			issues = append(issues, issue)
+		}
+	}
+	queue.Issues = issues
+
+	return queue.save()
+}
+
+// OfflineTracker pretends to be the tracker for `report --offline`
+// putting the issues into the queue instead of creating them
+type OfflineTracker struct {
+	Queue *OfflineQueue
+}
+
+func (tracker OfflineTracker) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	return Issue{}, fmt.Errorf("%s is not reported yet. Run `snitch report --flush` first", *todo.ID)
+}
+
+func (tracker OfflineTracker) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	id, err := tracker.Queue.push(todo, body)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return Issue{
+		ID:    id,
+		Title: todo.Title,
+		State: issueOpen,
+	}, nil
+}
+
+func (tracker OfflineTracker) getHost() string {
+	return "offline"
+}
+
+func (tracker OfflineTracker) projectURL(repo string) string {
+	return tracker.Queue.path
+}
+
+func (tracker OfflineTracker) issueURL(repo string, todo Todo) string {
+	return tracker.Queue.path
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestOfflineQueue_ReportAndFlush(t *testing.T) {
+	dir, cleanup := chdirTempGitRepo(t, map[string]string{
+		"main.go": "package main\n\n// TODO: Rewrite this in rust\n// TODO: And then in zig\nfunc main() {}\n",
+		"util.go": "package main\n\n// TODO: Remove this file\n",
+	})
+	defer cleanup()
+
+	project := Project{
+		Title:         &TitleConfig{},
+		Keywords:      []string{"TODO"},
+		BodySeparator: defaultBodySeparator,
+	}
+
+	queuePath, err := offlineQueuePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := loadOfflineQueue(queuePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acceptAll := func(todo Todo) bool { return true }
+	err = reportSubcommand(context.Background(), project, OfflineTracker{Queue: queue}, "", "", true, acceptAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile("main.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	want := "package main\n\n// TODO(pending-1): Rewrite this in rust\n// TODO(pending-2): And then in zig\nfunc main() {}\n"
+	if string(b) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	// The queue survives between the runs
+	queue, err = loadOfflineQueue(queuePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queue.Issues) != 3 || queue.Issues[2].Title != "Remove this file" {
+		t.Fatalf("unexpected queue %v", queue.Issues)
+	}
+
+	// The TODO was removed before the network came back
+	if err := ioutil.WriteFile("util.go", []byte("package main\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+	git(t, "commit", "-q", "-am", "Remove util.go")
+
+	repo, tracker, err := getLocalTracker(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := flushSubcommand(context.Background(), project, tracker, repo, queue); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = ioutil.ReadFile("main.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	want = "package main\n\n// TODO(#1): Rewrite this in rust\n// TODO(#2): And then in zig\nfunc main() {}\n"
+	if string(b) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	queue, err = loadOfflineQueue(queuePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queue.Issues) != 0 || queue.Next != 4 {
+		t.Errorf("the queue is not flushed: %v", queue)
+	}
+
+	issue, err := tracker.getIssue(context.Background(), repo, Todo{ID: stringPtr("#2")})
+	if err != nil || issue.Title != "And then in zig" {
+		t.Errorf("got issue %v, %v", issue, err)
+	}
+
+	status, err := runGit("", "status", "--porcelain", "--untracked-files=no")
+	if err != nil || len(strings.TrimSpace(status)) != 0 {
+		t.Errorf("the flush is not committed: %q, %v", status, err)
+	}
+}
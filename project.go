@@ -5,10 +5,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
@@ -49,6 +52,33 @@ type Project struct {
 	Keywords      []string
 	BodySeparator string
 	Remote        string
+	// Assignees maps commit author emails to tracker usernames
+	Assignees map[string]string
+	// Tracker overrides the issue tracker detected from the remote.
+	// Supported values: jira, local, git, redmine, generic
+	Tracker   string
+	Jira      *JiraConfig
+	Local     *LocalConfig
+	Azure     *AzureConfig
+	Sourcehut *SourcehutConfig
+	Redmine   *RedmineConfig
+	Generic   *GenericConfig
+}
+
+// AssigneeOf finds the tracker username of the author of the blamed
+// line. Returns an empty string if the author is unknown.
+func (project Project) AssigneeOf(blame *Blame) string {
+	if blame == nil || !blame.IsCommitted() {
+		return ""
+	}
+
+	for email, username := range project.Assignees {
+		if strings.EqualFold(email, blame.AuthorEmail) {
+			return username
+		}
+	}
+
+	return ""
 }
 
 func unreportedTodoRegexp(keyword string) string {
@@ -132,27 +162,33 @@ func (project Project) LineAsTodo(line string) *Todo {
 
 // WalkTodosOfFile visits all of the TODOs in a particular file
 func (project Project) WalkTodosOfFile(path string, visit func(Todo) error) error {
-	file, err := os.Open(path)
+	info, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	reader := bufio.NewReader(file)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	snapshot := newFileSnapshot(info, content)
+	reader := bufio.NewReader(bytes.NewReader(content))
 
 	var todo *Todo
 
-	text, _, err := reader.ReadLine()
+	text, err := readLine(reader)
 	for line := 1; err == nil; line = line + 1 {
 		if todo == nil { // LookingForTodo
-			todo = project.LineAsTodo(string(text))
+			todo = project.LineAsTodo(text)
 
 			if todo != nil { // Switch to CollectingBody
 				todo.Filename = path
 				todo.Line = line
+				todo.snapshot = snapshot
 			}
 		} else { // CollectingBody
-			if possibleTodo := project.LineAsTodo(string(text)); possibleTodo != nil {
+			if possibleTodo := project.LineAsTodo(text); possibleTodo != nil {
 				if err := visit(*todo); err != nil {
 					return err
 				}
@@ -160,12 +196,13 @@ func (project Project) WalkTodosOfFile(path string, visit func(Todo) error) erro
 				todo = possibleTodo // Remain in CollectingBody but for the next todo
 				todo.Filename = path
 				todo.Line = line
-			} else if todo.IsBodySeperator(string(text)) {
+				todo.snapshot = snapshot
+			} else if todo.IsBodySeperator(text) {
 				if err := visit(*todo); err != nil {
 					return err
 				}
 				todo = nil // Switch to LookingForTodo
-			} else if bodyLine := todo.ParseBodyLine(string(text)); bodyLine != nil {
+			} else if bodyLine := todo.ParseBodyLine(text); bodyLine != nil {
 				todo.Body = append(todo.Body, *bodyLine)
 			} else {
 				if err := visit(*todo); err != nil {
@@ -176,7 +213,7 @@ func (project Project) WalkTodosOfFile(path string, visit func(Todo) error) erro
 			}
 		}
 
-		text, _, err = reader.ReadLine()
+		text, err = readLine(reader)
 	}
 
 	if todo != nil {
@@ -193,8 +230,19 @@ func (project Project) WalkTodosOfFile(path string, visit func(Todo) error) erro
 	return nil
 }
 
-// WalkTodosOfDir visits all of the TODOs in a particular directory
+// WalkTodosOfDir visits all of the TODOs in a particular directory.
+// Git repos are walked through `git ls-files`, everything else is
+// walked through the filesystem respecting the ignore files.
 func (project Project) WalkTodosOfDir(dirpath string, visit func(Todo) error) error {
+	if _, err := locateDotGit(dirpath); err != nil {
+		relPath, rules := rootIgnoreRules(dirpath)
+		return project.walkTodosOfFilesystem(dirpath, relPath, rules, visit)
+	}
+
+	return project.walkTodosOfGit(dirpath, visit)
+}
+
+func (project Project) walkTodosOfGit(dirpath string, visit func(Todo) error) error {
 	cmd := exec.Command("git", "ls-files", dirpath)
 	var outb bytes.Buffer
 	cmd.Stdout = &outb
@@ -268,3 +316,75 @@ func NewProject(filePath string) (*Project, error) {
 
 	return project, nil
 }
+
+// rootIgnoreRules loads the .hgignore of the project root. Its rules
+// are relative to the root, so the path of dirpath relative to the
+// root is returned too for matching them.
+func rootIgnoreRules(dirpath string) (string, []ignoreRule) {
+	root, err := locateProject(dirpath)
+	if err != nil {
+		return "", []ignoreRule{}
+	}
+
+	absDir, err := filepath.Abs(dirpath)
+	if err != nil {
+		return "", []ignoreRule{}
+	}
+
+	relPath, err := filepath.Rel(root, absDir)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", []ignoreRule{}
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "." {
+		relPath = ""
+	}
+
+	return relPath, loadIgnoreFile(path.Join(root, hgignoreFile), "")
+}
+
+// walkTodosOfFilesystem visits all of the TODOs in the dirpath
+// directory recursively. relPath is the path of dirpath relative to
+// the root of the walk and is used for matching the ignore rules.
+func (project Project) walkTodosOfFilesystem(dirpath string, relPath string, rules []ignoreRule, visit func(Todo) error) error {
+	for _, ignoreFile := range ignoreFiles {
+		rules = append(rules, loadIgnoreFile(path.Join(dirpath, ignoreFile), relPath)...)
+	}
+
+	entries, err := ioutil.ReadDir(dirpath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entryPath := path.Join(dirpath, entry.Name())
+		entryRelPath := path.Join(relPath, entry.Name())
+
+		if isIgnored(rules, entryRelPath, entry.IsDir()) {
+			continue
+		}
+
+		if entry.IsDir() {
+			// Copying the rules so the siblings don't see each other's ignore files
+			dirRules := make([]ignoreRule, len(rules))
+			copy(dirRules, rules)
+
+			if err := project.walkTodosOfFilesystem(entryPath, entryRelPath, dirRules, visit); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Symlinks, sockets, devices, etc are not followed
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+
+		if err := project.WalkTodosOfFile(entryPath, visit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
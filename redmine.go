@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+var defaultRedmineClosedStatuses = []string{"Closed", "Rejected"}
+
+// RedmineConfig contains the project level configuration of the Redmine tracker
+type RedmineConfig struct {
+	Host    string
+	Project string
+	// ClosedStatuses lists the statuses considered closed on the
+	// instances that don't report is_closed (before Redmine 5.1)
+	ClosedStatuses []string `yaml:"closed_statuses"`
+}
+
+// RedmineCredentials contains APIKey for Redmine API authorization
+// and Host of the Redmine instance
+type RedmineCredentials struct {
+	Host   string
+	APIKey string
+
+	ClosedStatuses []string
+
+	// apiURL is only overridden in tests
+	apiURL string
+}
+
+func (creds RedmineCredentials) baseURL() string {
+	if len(creds.apiURL) > 0 {
+		return creds.apiURL
+	}
+
+	return "https://" + creds.Host
+}
+
+// redmineIssue is the part of a Redmine issue snitch cares about.
+// Redmine wraps it into {"issue": ...} both ways.
+type redmineIssue struct {
+	Issue struct {
+		ID         *int           `json:"id"`
+		Subject    string         `json:"subject"`
+		Status     *redmineStatus `json:"status"`
+		AssignedTo *struct {
+			Name string `json:"name"`
+		} `json:"assigned_to"`
+	} `json:"issue"`
+}
+
+type redmineStatus struct {
+	Name string `json:"name"`
+	// IsClosed is only reported by Redmine 5.1+
+	IsClosed *bool `json:"is_closed"`
+}
+
+func (creds RedmineCredentials) query(ctx context.Context, method, url string, jsonBody map[string]interface{}, v interface{}) error {
+	bodyBuffer := new(bytes.Buffer)
+	err := json.NewEncoder(bodyBuffer).Encode(jsonBody)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyBuffer)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("X-Redmine-API-Key", creds.APIKey)
+	req.Header.Add("Content-Type", "application/json")
+
+	return QueryHTTP(req, v)
+}
+
+func (creds RedmineCredentials) isClosedStatus(status redmineStatus) bool {
+	if status.IsClosed != nil {
+		return *status.IsClosed
+	}
+
+	closedStatuses := creds.ClosedStatuses
+	if len(closedStatuses) == 0 {
+		closedStatuses = defaultRedmineClosedStatuses
+	}
+
+	for _, closedStatus := range closedStatuses {
+		if strings.EqualFold(status.Name, closedStatus) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (creds RedmineCredentials) normalize(issue redmineIssue) (Issue, error) {
+	if issue.Issue.ID == nil {
+		return Issue{}, errMissingField("Redmine", "issue.id")
+	}
+
+	if issue.Issue.Status == nil {
+		return Issue{}, errMissingField("Redmine", "issue.status")
+	}
+
+	var assignees []string
+	if issue.Issue.AssignedTo != nil {
+		assignees = append(assignees, issue.Issue.AssignedTo.Name)
+	}
+
+	return Issue{
+		ID:        "#" + strconv.Itoa(*issue.Issue.ID),
+		Title:     issue.Issue.Subject,
+		State:     issueState(creds.isClosedStatus(*issue.Issue.Status)),
+		Assignees: assignees,
+	}, nil
+}
+
+func (creds RedmineCredentials) getIssue(ctx context.Context, project string, todo Todo) (Issue, error) {
+	id, err := issueNumber(todo)
+	if err != nil {
+		return Issue{}, fmt.Errorf("%s is not a Redmine issue ID", *todo.ID)
+	}
+
+	issue := redmineIssue{}
+	err = creds.query(ctx, "GET", creds.baseURL()+"/issues/"+strconv.Itoa(id)+".json", nil, &issue)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return creds.normalize(issue)
+}
+
+func (creds RedmineCredentials) postIssue(ctx context.Context, project string, todo Todo, body string) (Issue, error) {
+	params := map[string]interface{}{
+		"project_id":  project,
+		"subject":     todo.Title,
+		"description": body,
+	}
+
+	if len(todo.Assignee) > 0 {
+		// Redmine assigns the issues by the IDs of the users
+		userID, err := strconv.Atoi(todo.Assignee)
+		if err != nil {
+			return Issue{}, fmt.Errorf("Redmine assignee must be a user ID, got %s", todo.Assignee)
+		}
+		params["assigned_to_id"] = userID
+	}
+
+	issue := redmineIssue{}
+	err := creds.query(ctx, "POST", creds.baseURL()+"/issues.json",
+		map[string]interface{}{"issue": params}, &issue)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return creds.normalize(issue)
+}
+
+func (creds RedmineCredentials) getHost() string {
+	return creds.Host
+}
+
+func (creds RedmineCredentials) projectURL(project string) string {
+	return "https://" + creds.Host + "/projects/" + project
+}
+
+func (creds RedmineCredentials) issueURL(project string, todo Todo) string {
+	return "https://" + creds.Host + "/issues/" + strings.TrimPrefix(*todo.ID, "#")
+}
+
+// RedmineCredentialsFromFile gets RedmineCredentials from a filepath
+func RedmineCredentialsFromFile(filepath string) []RedmineCredentials {
+	credentials := []RedmineCredentials{}
+
+	cfg, err := ini.Load(filepath)
+	if err != nil {
+		return credentials
+	}
+
+	for _, section := range cfg.Sections()[1:] {
+		credentials = append(credentials, RedmineCredentials{
+			Host:   section.Name(),
+			APIKey: section.Key("api_key").String(),
+		})
+	}
+
+	return credentials
+}
+
+// RedmineCredentialsFromToken returns a RedmineCredentials from a
+// `<host>:<api-key>` string
+func RedmineCredentialsFromToken(token string) (RedmineCredentials, error) {
+	credentials := strings.Split(token, ":")
+
+	if len(credentials) != 2 {
+		return RedmineCredentials{},
+			fmt.Errorf("Couldn't parse Redmine credentials from ENV: %s", token)
+	}
+
+	return RedmineCredentials{
+		Host:   credentials[0],
+		APIKey: credentials[1],
+	}, nil
+}
+
+func getRedmineCredentials() []RedmineCredentials {
+	creds := []RedmineCredentials{}
+	tokenEnvar := os.Getenv("REDMINE_API_KEY")
+	xdgEnvar := os.Getenv("XDG_CONFIG_HOME")
+	usr, err := user.Current()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(tokenEnvar) != 0 {
+		for _, credential := range strings.Split(tokenEnvar, ",") {
+			parsedCredentials, err := RedmineCredentialsFromToken(credential)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			creds = append(creds, parsedCredentials)
+		}
+	}
+
+	// custom XDG_CONFIG_HOME
+	if len(xdgEnvar) != 0 {
+		filePath := path.Join(xdgEnvar, "snitch/redmine.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			creds = append(creds, RedmineCredentialsFromFile(filePath)...)
+		}
+	}
+
+	// default XDG_CONFIG_HOME
+	if len(xdgEnvar) == 0 {
+		filePath := path.Join(usr.HomeDir, ".config/snitch/redmine.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			creds = append(creds, RedmineCredentialsFromFile(filePath)...)
+		}
+	}
+
+	filePath := path.Join(usr.HomeDir, ".snitch/redmine.ini")
+	if _, err := os.Stat(filePath); err == nil {
+		creds = append(creds, RedmineCredentialsFromFile(filePath)...)
+	}
+
+	return creds
+}
+
+// getRedmineTracker finds the credentials for the Redmine instance
+// configured in .snitch.yaml. The "repo" of a Redmine tracker is the
+// identifier of the Redmine project.
+func getRedmineTracker(config *RedmineConfig) (string, IssueAPI, error) {
+	if config == nil || len(config.Host) == 0 || len(config.Project) == 0 {
+		return "", nil, fmt.Errorf("Redmine tracker requires `redmine.host' and `redmine.project' in .snitch.yaml")
+	}
+
+	for _, creds := range getRedmineCredentials() {
+		if creds.Host == config.Host {
+			creds.ClosedStatuses = config.ClosedStatuses
+			return config.Project, creds, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("No Redmine credentials have been found for %s. Read https://github.com/tsoding/snitch#redmine-credentials", config.Host)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedmineCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Redmine-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/issues.json":
+			var request struct {
+				Issue struct {
+					ProjectID string `json:"project_id"`
+					Subject   string
+				}
+			}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Issue.ProjectID != "snitch" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"issue": {"id": 5, "status": {"id": 1, "name": "New"}}}`))
+		case r.Method == "GET" && r.URL.Path == "/issues/5.json":
+			w.Write([]byte(`{"issue": {"id": 5, "status": {"id": 6, "name": "Rejected"}}}`))
+		case r.Method == "GET" && r.URL.Path == "/issues/6.json":
+			w.Write([]byte(`{"issue": {"id": 6, "status": {"id": 7, "name": "Verified", "is_closed": true}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	creds := RedmineCredentials{Host: "redmine.example.com", APIKey: "secret", apiURL: server.URL}
+
+	todo, err := Todo{Title: "Rewrite this in Rust"}.Report(context.Background(), creds, "snitch", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := derefString(todo.ID); got != "#5" {
+		t.Errorf("got ID %q, want %q", got, "#5")
+	}
+
+	for _, id := range []string{"#5", "#6"} {
+		status, err := Todo{ID: stringPtr(id)}.RetrieveStatus(context.Background(), creds, "snitch", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if status != "closed" {
+			t.Errorf("%s: got status %q, want %q", id, status, "closed")
+		}
+	}
+}
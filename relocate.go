@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// locationMarkerRegexp finds the location of the TODO remembered in
+// the issue body along with the visible Location line that follows it.
+// The filename is escaped, so it can't contain the spaces or the end of
+// the comment. The trackers may turn the line breaks into \r\n once
+// the body is edited through the web interface.
+var locationMarkerRegexp = regexp.MustCompile(`<!-- snitch-location: ([^\s>]+):([0-9]+) -->(?:\r?\nLocation: [^\r\n]*)?`)
+
+// IssueRelocator is implemented by the trackers that keep the location
+// of the TODO in the issue body up to date
+type IssueRelocator interface {
+	getIssueBody(ctx context.Context, repo string, todo Todo) (string, error)
+	editIssueBody(ctx context.Context, repo string, todo Todo, body string) error
+	commentIssue(ctx context.Context, repo string, todo Todo, comment string) error
+	// permalink is the link to the line of the file at the commit
+	permalink(repo string, commit string, filename string, line int) string
+}
+
+// TodoLocation is where the TODO is. Filename is relative to the root
+// of the repo, so it doesn't depend on where snitch is run from.
+type TodoLocation struct {
+	Filename string
+	Line     int
+}
+
+func (location TodoLocation) String() string {
+	return location.Filename + ":" + strconv.Itoa(location.Line)
+}
+
+// todoLocation finds the location of the todo relative to the root of
+// the repo
+func todoLocation(todo Todo) (TodoLocation, error) {
+	prefix, err := runGit("", "rev-parse", "--show-prefix")
+	if err != nil {
+		return TodoLocation{}, err
+	}
+
+	return TodoLocation{
+		Filename: path.Join(prefix, filepath.ToSlash(todo.Filename)),
+		Line:     todo.Line,
+	}, nil
+}
+
+// locationSection is the part of the issue body that remembers the
+// location of the TODO. The marker is hidden by the Markdown renderers
+// of the trackers.
+func locationSection(location TodoLocation, link string) string {
+	text := "`" + location.String() + "`"
+	if len(link) > 0 {
+		text = "[" + location.String() + "](" + link + ")"
+	}
+
+	return fmt.Sprintf("<!-- snitch-location: %s:%d -->\nLocation: %s",
+		escapeFilename(location.Filename), location.Line, text)
+}
+
+// parseLocation finds the location remembered in the issue body. The
+// issues reported by the older versions of snitch don't have it.
+func parseLocation(body string) (TodoLocation, bool) {
+	groups := locationMarkerRegexp.FindStringSubmatch(body)
+	if groups == nil {
+		return TodoLocation{}, false
+	}
+
+	filename, err := url.PathUnescape(groups[1])
+	if err != nil {
+		return TodoLocation{}, false
+	}
+
+	line, err := strconv.Atoi(groups[2])
+	if err != nil {
+		return TodoLocation{}, false
+	}
+
+	return TodoLocation{Filename: filename, Line: line}, true
+}
+
+// replaceLocation puts the section in place of the first location
+// remembered in the body
+func replaceLocation(body string, section string) string {
+	replaced := false
+	return locationMarkerRegexp.ReplaceAllStringFunc(body, func(match string) string {
+		if replaced {
+			return match
+		}
+		replaced = true
+		return section
+	})
+}
+
+// withLocation appends the location of the todo to the issue body for
+// the trackers that can relocate it later
+func withLocation(creds IssueAPI, body string, todo Todo) string {
+	if _, ok := creds.(IssueRelocator); !ok {
+		return body
+	}
+
+	location, err := todoLocation(todo)
+	if err != nil {
+		return body
+	}
+
+	return body + "\n\n" + locationSection(location, "")
+}
+
+// escapeFilename escapes the segments of the filename for the links
+func escapeFilename(filename string) string {
+	segments := strings.Split(filename, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// relocateSubcommand updates the location of the TODOs in the issues
+// whose TODOs have moved since they were reported or relocated
+func relocateSubcommand(ctx context.Context, project Project, creds IssueAPI, repo string, comment bool, alwaysYes bool) error {
+	commit, err := runGit("", "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+
+	// The trackers can't show the commits that only exist locally
+	branches, err := runGit("", "branch", "-r", "--contains", commit)
+	pushed := err == nil && len(branches) > 0
+
+	// The same issue may be referred to by many TODOs, the first one
+	// is considered its location
+	reportedTodos := []Todo{}
+	seen := map[string]bool{}
+	err = project.WalkTodosOfDir(".", func(todo Todo) error {
+		if todo.ID == nil || isPendingID(*todo.ID) || seen[*todo.ID] {
+			return nil
+		}
+
+		seen[*todo.ID] = true
+		reportedTodos = append(reportedTodos, todo)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	resolver := newIssueResolver(project, creds, repo)
+	for _, todo := range reportedTodos {
+		if err := relocateTodo(ctx, resolver, todo, commit, pushed, comment, alwaysYes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func relocateTodo(ctx context.Context, resolver IssueResolver, todo Todo, commit string, pushed bool, comment bool, alwaysYes bool) error {
+	creds, repo, resolved, err := resolver.resolve(todo)
+	if err != nil {
+		fmt.Printf("[WARN] %s: %s\n", todo.LogString(), err)
+		return nil
+	}
+
+	relocator, ok := creds.(IssueRelocator)
+	if !ok {
+		fmt.Printf("[WARN] %s: the issues of %s can't be relocated\n", todo.LogString(), creds.getHost())
+		return nil
+	}
+
+	// The issue may live in another repo but the TODO is always in the
+	// current one, so is the link to it
+	linker, ok := resolver.Creds.(IssueRelocator)
+	if !ok {
+		fmt.Printf("[WARN] %s: %s can't link to the TODOs\n", todo.LogString(), resolver.Creds.getHost())
+		return nil
+	}
+
+	body, err := relocator.getIssueBody(ctx, repo, resolved)
+	if errors.Is(err, ErrNotFound) {
+		fmt.Printf("[NOT FOUND] %v\n", todo.LogString())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Couldn't retrieve the issue of %s\n%w", todo.LogString(), err)
+	}
+
+	oldLocation, ok := parseLocation(body)
+	if !ok {
+		fmt.Printf("[SKIP] %v: the issue doesn't remember the location of the TODO\n", todo.LogString())
+		return nil
+	}
+
+	location, err := todoLocation(todo)
+	if err != nil {
+		return err
+	}
+
+	if location == oldLocation {
+		return nil
+	}
+
+	fmt.Printf("[MOVED] %v (was %s)\n", todo.LogString(), oldLocation)
+	fmt.Printf("Issue link: %s\n", issueURL(creds, repo, resolved))
+
+	// The permalink points to the last commit, the uncommitted
+	// changes would make it point to the wrong line
+	if status, err := runGit("", "status", "--porcelain", "--", todo.Filename); err != nil || len(status) > 0 {
+		fmt.Printf("[WARN] %s has uncommitted changes. Commit them first\n", todo.Filename)
+		return nil
+	}
+
+	if !pushed {
+		fmt.Printf("[WARN] %s: HEAD is not pushed to any remote branch. Push it first\n", todo.LogString())
+		return nil
+	}
+
+	yes, err := yOrN("The TODO has moved. Do you want to update the issue?", alwaysYes)
+	if err != nil || !yes {
+		return err
+	}
+
+	link := linker.permalink(resolver.Repo, commit, location.Filename, location.Line)
+	body = replaceLocation(body, locationSection(location, link))
+	if err := relocator.editIssueBody(ctx, repo, resolved, body); err != nil {
+		return fmt.Errorf("Couldn't update the issue of %s\n%w", todo.LogString(), err)
+	}
+
+	if comment {
+		message := fmt.Sprintf("The TODO has moved from `%s` to [%s](%s)", oldLocation, location, link)
+		if err := relocator.commentIssue(ctx, repo, resolved, message); err != nil {
+			return fmt.Errorf("Couldn't comment on the issue of %s\n%w", todo.LogString(), err)
+		}
+	}
+
+	fmt.Printf("[RELOCATED] %v\n", todo.LogString())
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocation_ParseAndReplace(t *testing.T) {
+	body := "Rewrite it\r\n\r\n<!-- snitch-location: src/main.go:42 -->\r\nLocation: `src/main.go:42`\r\n\r\nThanks"
+
+	location, ok := parseLocation(body)
+	if !ok || location != (TodoLocation{"src/main.go", 42}) {
+		t.Fatalf("got %v %v", location, ok)
+	}
+
+	section := locationSection(TodoLocation{"src/util.go", 7}, "https://example.com/util.go#L7")
+	want := "Rewrite it\r\n\r\n<!-- snitch-location: src/util.go:7 -->\nLocation: [src/util.go:7](https://example.com/util.go#L7)\r\n\r\nThanks"
+	if got := replaceLocation(body, section); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, ok := parseLocation("Reported before the locations"); ok {
+		t.Errorf("found the location in the body without the marker")
+	}
+
+	// The filenames that could break out of the marker are escaped
+	location = TodoLocation{"docs/a --> b.md", 7}
+	section = locationSection(location, "")
+	if got, ok := parseLocation("Rewrite it\n\n" + section); !ok || got != location {
+		t.Errorf("got %v %v from %q", got, ok, section)
+	}
+
+	// Only the Location line belongs to the marker
+	body = "<!-- snitch-location: main.go:1 -->\nThanks"
+	if got := replaceLocation(body, "<!-- snitch-location: main.go:3 -->"); got != "<!-- snitch-location: main.go:3 -->\nThanks" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRelocateSubcommand(t *testing.T) {
+	_, cleanup := chdirTempGitRepo(t, map[string]string{
+		"main.go": "package main\n\n// TODO(#1): Rewrite this in Rust\n// TODO(#2): And then in Zig\n// TODO(alice/other#3): And then in Odin\n",
+	})
+	defer cleanup()
+
+	commit, err := runGit("", "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := map[string]string{
+		"snitch/1": "Moved\n\n<!-- snitch-location: main.go:1 -->\nLocation: `main.go:1`",
+		"snitch/2": "Not moved\n\n<!-- snitch-location: main.go:4 -->\nLocation: `main.go:4`",
+		"other/3":  "Moved from the other repo\n\n<!-- snitch-location: main.go:2 -->\nLocation: `main.go:2`",
+	}
+	edited := map[string]string{}
+	comments := map[string]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /api/v1/repos/alice/<repo>/issues/<number>[/comments]
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/repos/alice/"), "/")
+		if len(parts) < 3 || parts[1] != "issues" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			return
+		}
+		number := parts[0] + "/" + parts[2]
+		parts = parts[2:]
+
+		request := struct {
+			Body string `json:"body"`
+		}{}
+		if r.Method != "GET" {
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Error(err)
+			}
+		}
+
+		switch {
+		case r.Method == "GET":
+			json.NewEncoder(w).Encode(map[string]interface{}{"number": 1, "state": "open", "body": bodies[number]})
+			return
+		case r.Method == "PATCH":
+			edited[number] = request.Body
+		case r.Method == "POST" && len(parts) == 2 && parts[1] == "comments":
+			comments[number] = request.Body
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"number": 1, "state": "open"}`))
+	}))
+	defer server.Close()
+
+	project := Project{
+		Title:    &TitleConfig{},
+		Keywords: []string{"TODO"},
+	}
+	creds := GiteaCredentials{Host: "gitea.example.com", Instance: Instance{BaseURL: server.URL}}
+
+	// The permalinks would point to a commit the tracker doesn't know
+	if err := relocateSubcommand(context.Background(), project, creds, "alice/snitch", true, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(edited) > 0 || len(comments) > 0 {
+		t.Fatalf("the issues are updated before HEAD is pushed: %v %v", edited, comments)
+	}
+
+	git(t, "update-ref", "refs/remotes/origin/master", "HEAD")
+	if err := relocateSubcommand(context.Background(), project, creds, "alice/snitch", true, true); err != nil {
+		t.Fatal(err)
+	}
+
+	link := server.URL + "/alice/snitch/src/commit/" + commit + "/main.go#L3"
+	wantBody := "Moved\n\n<!-- snitch-location: main.go:3 -->\nLocation: [main.go:3](" + link + ")"
+	if got := edited["snitch/1"]; got != wantBody {
+		t.Errorf("got %q, want %q", got, wantBody)
+	}
+
+	wantComment := "The TODO has moved from `main.go:1` to [main.go:3](" + link + ")"
+	if got := comments["snitch/1"]; got != wantComment {
+		t.Errorf("got %q, want %q", got, wantComment)
+	}
+
+	if _, ok := edited["snitch/2"]; ok {
+		t.Errorf("the issue of the TODO that hasn't moved is edited")
+	}
+
+	// The issue lives in the other repo, the TODO is still in this one
+	otherLink := server.URL + "/alice/snitch/src/commit/" + commit + "/main.go#L5"
+	wantOtherBody := "Moved from the other repo\n\n<!-- snitch-location: main.go:5 -->\nLocation: [main.go:5](" + otherLink + ")"
+	if got := edited["other/3"]; got != wantOtherBody {
+		t.Errorf("got %q, want %q", got, wantOtherBody)
+	}
+
+	todo := Todo{Filename: "./main.go", Line: 3}
+	wantReported := "body\n\n<!-- snitch-location: main.go:3 -->\nLocation: `main.go:3`"
+	if got := withLocation(creds, "body", todo); got != wantReported {
+		t.Errorf("got %q, want %q", got, wantReported)
+	}
+
+	if got := withLocation(LocalTracker{}, "body", todo); got != "body" {
+		t.Errorf("the location is added for the tracker that can't relocate the issues: %q", got)
+	}
+}
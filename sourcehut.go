@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// SourcehutConfig contains the project level configuration of the
+// SourceHut tracker
+type SourcehutConfig struct {
+	// Tracker is the name of the todo.sr.ht tracker. By default it's
+	// the name of the git repo.
+	Tracker string
+}
+
+// SourcehutCredentials contains PersonalToken for todo.sr.ht GraphQL
+// API authorization and Host of the SourceHut instance (sr.ht by
+// default)
+type SourcehutCredentials struct {
+	Host          string
+	PersonalToken string
+
+	// apiURL is only overridden in tests
+	apiURL string
+}
+
+func (creds SourcehutCredentials) baseURL() string {
+	if len(creds.apiURL) > 0 {
+		return creds.apiURL
+	}
+
+	return "https://todo." + creds.Host + "/query"
+}
+
+// configure replaces the name of the tracker with the one from .snitch.yaml
+func (creds SourcehutCredentials) configure(project Project, repo string) (string, IssueAPI) {
+	if project.Sourcehut != nil && len(project.Sourcehut.Tracker) > 0 {
+		if owner, _, err := creds.splitRepo(repo); err == nil {
+			repo = "~" + owner + "/" + project.Sourcehut.Tracker
+		}
+	}
+
+	return repo, creds
+}
+
+// sourcehutTicket is the part of a todo.sr.ht ticket snitch cares about
+type sourcehutTicket struct {
+	ID      *int   `json:"id"`
+	Subject string `json:"subject"`
+	// Status is one of REPORTED, CONFIRMED, IN_PROGRESS, PENDING
+	// or RESOLVED
+	Status    string `json:"status"`
+	Assignees []struct {
+		CanonicalName string `json:"canonicalName"`
+	} `json:"assignees"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+type sourcehutTracker struct {
+	ID     *int             `json:"id"`
+	Ticket *sourcehutTicket `json:"ticket"`
+}
+
+// sourcehutData is the data of all of the queries snitch makes
+type sourcehutData struct {
+	User *struct {
+		Tracker *sourcehutTracker `json:"tracker"`
+	} `json:"user"`
+	SubmitTicket *sourcehutTicket `json:"submitTicket"`
+}
+
+// graphql runs a GraphQL query and returns its data
+func (creds SourcehutCredentials) graphql(ctx context.Context, query string, variables map[string]interface{}) (sourcehutData, error) {
+	data := sourcehutData{}
+
+	bodyBuffer := new(bytes.Buffer)
+	err := json.NewEncoder(bodyBuffer).Encode(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return data, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", creds.baseURL(), bodyBuffer)
+	if err != nil {
+		return data, err
+	}
+
+	req.Header.Add("Authorization", "Bearer "+creds.PersonalToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	response := struct {
+		Data   *sourcehutData `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	if err := QueryHTTP(req, &response); err != nil {
+		return data, err
+	}
+
+	// GraphQL reports the errors with 200 OK
+	if len(response.Errors) > 0 {
+		messages := []string{}
+		for _, e := range response.Errors {
+			messages = append(messages, e.Message)
+		}
+		return data, fmt.Errorf("SourceHut API error: %s", strings.Join(messages, "; "))
+	}
+
+	if response.Data == nil {
+		return data, errMissingField("SourceHut", "data")
+	}
+
+	return *response.Data, nil
+}
+
+// splitRepo splits ~owner/tracker into the owner's username and the
+// name of the tracker
+func (creds SourcehutCredentials) splitRepo(repo string) (string, string, error) {
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "~") {
+		return "", "", fmt.Errorf("%s is not a SourceHut tracker", repo)
+	}
+
+	return strings.TrimPrefix(parts[0], "~"), parts[1], nil
+}
+
+func (creds SourcehutCredentials) trackerField(data sourcehutData, repo string) (*sourcehutTracker, error) {
+	if data.User == nil || data.User.Tracker == nil {
+		return nil, fmt.Errorf("SourceHut tracker %s is not found", repo)
+	}
+
+	return data.User.Tracker, nil
+}
+
+func (creds SourcehutCredentials) getIssue(ctx context.Context, repo string, todo Todo) (Issue, error) {
+	username, trackerName, err := creds.splitRepo(repo)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	id, err := issueNumber(todo)
+	if err != nil {
+		return Issue{}, fmt.Errorf("%s is not a SourceHut ticket ID", *todo.ID)
+	}
+
+	data, err := creds.graphql(ctx, `query ($username: String!, $tracker: String!, $id: Int!) {
+  user(username: $username) {
+    tracker(name: $tracker) {
+      ticket(id: $id) {
+        id subject status
+        assignees { canonicalName }
+        labels { name }
+      }
+    }
+  }
+}`, map[string]interface{}{"username": username, "tracker": trackerName, "id": id})
+	if err != nil {
+		return Issue{}, err
+	}
+
+	tracker, err := creds.trackerField(data, repo)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	if tracker.Ticket == nil {
+		return Issue{}, fmt.Errorf("SourceHut ticket %s is not found", *todo.ID)
+	}
+
+	if len(tracker.Ticket.Status) == 0 {
+		return Issue{}, errMissingField("SourceHut", "status")
+	}
+
+	var assignees []string
+	for _, assignee := range tracker.Ticket.Assignees {
+		assignees = append(assignees, assignee.CanonicalName)
+	}
+
+	var labels []string
+	for _, label := range tracker.Ticket.Labels {
+		labels = append(labels, label.Name)
+	}
+
+	return Issue{
+		ID:        "#" + strconv.Itoa(id),
+		Title:     tracker.Ticket.Subject,
+		State:     issueState(tracker.Ticket.Status == "RESOLVED"),
+		Assignees: assignees,
+		Labels:    labels,
+	}, nil
+}
+
+func (creds SourcehutCredentials) postIssue(ctx context.Context, repo string, todo Todo, body string) (Issue, error) {
+	username, trackerName, err := creds.splitRepo(repo)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	// submitTicket requires the internal ID of the tracker
+	data, err := creds.graphql(ctx, `query ($username: String!, $tracker: String!) {
+  user(username: $username) {
+    tracker(name: $tracker) { id }
+  }
+}`, map[string]interface{}{"username": username, "tracker": trackerName})
+	if err != nil {
+		return Issue{}, err
+	}
+
+	tracker, err := creds.trackerField(data, repo)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	if tracker.ID == nil {
+		return Issue{}, errMissingField("SourceHut", "tracker.id")
+	}
+
+	data, err = creds.graphql(ctx, `mutation ($trackerId: Int!, $input: SubmitTicketInput!) {
+  submitTicket(trackerId: $trackerId, input: $input) { id }
+}`, map[string]interface{}{
+		"trackerId": *tracker.ID,
+		"input": map[string]interface{}{
+			"subject": todo.Title,
+			"body":    body,
+		},
+	})
+	if err != nil {
+		return Issue{}, err
+	}
+
+	if data.SubmitTicket == nil || data.SubmitTicket.ID == nil {
+		return Issue{}, errMissingField("SourceHut", "submitTicket.id")
+	}
+
+	return Issue{
+		ID:    "#" + strconv.Itoa(*data.SubmitTicket.ID),
+		Title: todo.Title,
+		State: issueOpen,
+	}, nil
+}
+
+func (creds SourcehutCredentials) getHost() string {
+	return "git." + creds.Host
+}
+
+func (creds SourcehutCredentials) projectURL(repo string) string {
+	return "https://todo." + creds.Host + "/" + repo
+}
+
+func (creds SourcehutCredentials) issueURL(repo string, todo Todo) string {
+	return "https://todo." + creds.Host + "/" + repo + "/" + strings.TrimPrefix(*todo.ID, "#")
+}
+
+// matchRemote maps git.sr.ht/~owner/repo remotes to the ~owner/repo
+// todo.sr.ht tracker
+func (creds SourcehutCredentials) matchRemote(remoteURL string) (string, bool) {
+	remoteRegexp := regexp.MustCompile(regexp.QuoteMeta(creds.getHost()) + `[:/](~[-\.\w]+)/([-\.\w]+)$`)
+
+	groups := remoteRegexp.FindStringSubmatch(remoteURL)
+	if groups == nil {
+		return "", false
+	}
+
+	return groups[1] + "/" + groups[2], true
+}
+
+// SourcehutCredentialsFromFile gets SourcehutCredentials from a filepath
+func SourcehutCredentialsFromFile(filepath string) []SourcehutCredentials {
+	credentials := []SourcehutCredentials{}
+
+	cfg, err := ini.Load(filepath)
+	if err != nil {
+		return credentials
+	}
+
+	for _, section := range cfg.Sections()[1:] {
+		credentials = append(credentials, SourcehutCredentials{
+			Host:          section.Name(),
+			PersonalToken: section.Key("personal_token").String(),
+		})
+	}
+
+	return credentials
+}
+
+// SourcehutCredentialsFromToken returns a SourcehutCredentials from a string token
+func SourcehutCredentialsFromToken(token string) (SourcehutCredentials, error) {
+	credentials := strings.Split(token, ":")
+
+	switch len(credentials) {
+	case 1:
+		return SourcehutCredentials{
+			Host:          "sr.ht",
+			PersonalToken: credentials[0],
+		}, nil
+	case 2:
+		return SourcehutCredentials{
+			Host:          credentials[0],
+			PersonalToken: credentials[1],
+		}, nil
+	default:
+		return SourcehutCredentials{},
+			fmt.Errorf("Couldn't parse SourceHut credentials from ENV: %s", token)
+	}
+}
+
+func getSourcehutCredentials(creds []IssueAPI) []IssueAPI {
+	tokenEnvar := os.Getenv("SRHT_PERSONAL_TOKEN")
+	xdgEnvar := os.Getenv("XDG_CONFIG_HOME")
+	usr, err := user.Current()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(tokenEnvar) != 0 {
+		for _, credential := range strings.Split(tokenEnvar, ",") {
+			parsedCredentials, err := SourcehutCredentialsFromToken(credential)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			creds = append(creds, parsedCredentials)
+		}
+	}
+
+	// custom XDG_CONFIG_HOME
+	if len(xdgEnvar) != 0 {
+		filePath := path.Join(xdgEnvar, "snitch/sourcehut.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			for _, cred := range SourcehutCredentialsFromFile(filePath) {
+				creds = append(creds, cred)
+			}
+		}
+	}
+
+	// default XDG_CONFIG_HOME
+	if len(xdgEnvar) == 0 {
+		filePath := path.Join(usr.HomeDir, ".config/snitch/sourcehut.ini")
+		if _, err := os.Stat(filePath); err == nil {
+			for _, cred := range SourcehutCredentialsFromFile(filePath) {
+				creds = append(creds, cred)
+			}
+		}
+	}
+
+	filePath := path.Join(usr.HomeDir, ".snitch/sourcehut.ini")
+	if _, err := os.Stat(filePath); err == nil {
+		for _, cred := range SourcehutCredentialsFromFile(filePath) {
+			creds = append(creds, cred)
+		}
+	}
+
+	return creds
+}
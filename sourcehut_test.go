@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSourcehutCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Query     string
+			Variables map[string]interface{}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if request.Variables["username"] == "bob" {
+			w.Write([]byte(`{"data": {"user": null}, "errors": [{"message": "no such user"}]}`))
+			return
+		}
+
+		switch {
+		case strings.Contains(request.Query, "submitTicket"):
+			input, _ := request.Variables["input"].(map[string]interface{})
+			if request.Variables["trackerId"] != 7.0 || input["subject"] != "Rewrite this in Rust" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"data": {"submitTicket": {"id": 3}}}`))
+		case strings.Contains(request.Query, "ticket(id: $id)"):
+			w.Write([]byte(`{"data": {"user": {"tracker": {"ticket": {"id": 3, "status": "RESOLVED", "resolution": "FIXED"}}}}}`))
+		default:
+			w.Write([]byte(`{"data": {"user": {"tracker": {"id": 7}}}}`))
+		}
+	}))
+	defer server.Close()
+
+	creds := SourcehutCredentials{Host: "sr.ht", PersonalToken: "secret", apiURL: server.URL}
+
+	repo, ok := creds.matchRemote("git@git.sr.ht:~alice/snitch")
+	if !ok || repo != "~alice/snitch" {
+		t.Fatalf("got repo %q, want %q", repo, "~alice/snitch")
+	}
+
+	repo, _ = creds.configure(Project{Sourcehut: &SourcehutConfig{Tracker: "snitch-todo"}}, repo)
+	if repo != "~alice/snitch-todo" {
+		t.Fatalf("got repo %q, want %q", repo, "~alice/snitch-todo")
+	}
+
+	todo, err := Todo{Title: "Rewrite this in Rust"}.Report(context.Background(), creds, repo, "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := derefString(todo.ID); got != "#3" {
+		t.Errorf("got ID %q, want %q", got, "#3")
+	}
+
+	status, err := todo.RetrieveStatus(context.Background(), creds, repo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != "closed" {
+		t.Errorf("got status %q, want %q", status, "closed")
+	}
+
+	if _, err := todo.RetrieveStatus(context.Background(), creds, "~bob/snitch", nil); err == nil {
+		t.Errorf("expected GraphQL errors to be reported")
+	}
+}
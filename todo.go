@@ -2,9 +2,14 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -20,6 +25,14 @@ type Todo struct {
 	Title         string
 	Body          []string
 	BodySeparator string
+	// Blame is filled only on demand since it requires running git blame
+	Blame *Blame
+	// Assignee is the tracker username the reported issue is assigned to
+	Assignee string
+
+	// snapshot is shared between all of the Todos found in the
+	// same file during a single walk
+	snapshot *fileSnapshot
 }
 
 // LogString formats TODO for compilation logging. Format is
@@ -63,65 +76,101 @@ func (todo Todo) ParseBodyLine(line string) *string {
 	return nil
 }
 
-func (todo Todo) updateToFile(outputFilename string, lineCallback func(int, string) (string, bool)) error {
-	inputFile, err := os.Open(todo.Filename)
-	if err != nil {
+func (todo Todo) updateToFile(outputFile *os.File, content []byte, lineCallback func(int, string) (string, bool)) error {
+	writer := bufio.NewWriter(outputFile)
+	reader := bufio.NewReader(bytes.NewReader(content))
+
+	line, err := readLine(reader)
+	for lineNumber := 1; err == nil; lineNumber = lineNumber + 1 {
+		replace, remove := lineCallback(lineNumber, line)
+		if !remove {
+			fmt.Fprintln(writer, replace)
+		}
+
+		line, err = readLine(reader)
+	}
+
+	if err != io.EOF {
 		return err
 	}
-	defer inputFile.Close()
 
+	return writer.Flush()
+}
+
+func (todo Todo) updateInPlace(lineCallback func(int, string) (string, bool)) (err error) {
 	fileInfo, err := os.Stat(todo.Filename)
 	if err != nil {
 		return err
 	}
 
-	outputFile, err := os.Create(outputFilename)
+	content, err := ioutil.ReadFile(todo.Filename)
 	if err != nil {
 		return err
 	}
+
+	if !todo.snapshot.matches(fileInfo, content) {
+		return fmt.Errorf("%s has changed on disk since it was scanned. Refusing to overwrite it", todo.Filename)
+	}
+
+	// The temporary file is created next to the original one so the
+	// final rename stays on the same filesystem and is atomic.
+	outputFile, err := ioutil.TempFile(filepath.Dir(todo.Filename), filepath.Base(todo.Filename)+".snitch")
+	if err != nil {
+		return err
+	}
+	outputFilename := outputFile.Name()
 	defer func() {
-		cerr := outputFile.Close()
-		if cerr != nil {
-			err = cerr
+		if err != nil {
+			outputFile.Close()
+			os.Remove(outputFilename)
 		}
 	}()
 
-	scanner := bufio.NewScanner(inputFile)
-	lineNumber := 1
-
-	for scanner.Scan() {
-		line := scanner.Text()
+	if err = todo.updateToFile(outputFile, content, lineCallback); err != nil {
+		return err
+	}
 
-		replace, remove := lineCallback(lineNumber, line)
-		if !remove {
-			fmt.Fprintln(outputFile, replace)
-		}
+	if err = outputFile.Chmod(fileInfo.Mode()); err != nil {
+		return err
+	}
 
-		lineNumber = lineNumber + 1
+	if err = copyFileAttributes(todo.Filename, outputFile, fileInfo); err != nil {
+		return err
 	}
 
-	err = os.Chmod(outputFilename, fileInfo.Mode())
-	if err != nil {
+	if err = outputFile.Sync(); err != nil {
 		return err
 	}
 
-	return err
-}
+	if err = outputFile.Close(); err != nil {
+		return err
+	}
 
-func (todo Todo) updateInPlace(lineCallback func(int, string) (string, bool)) error {
-	outputFilename := todo.Filename + ".snitch"
-	err := todo.updateToFile(outputFilename, lineCallback)
+	// Narrow the window for clobbering an editor save that happened
+	// while we were producing the new content.
+	currentInfo, err := os.Stat(todo.Filename)
 	if err != nil {
 		return err
 	}
 
-	err = os.Rename(outputFilename, todo.Filename)
+	if !currentInfo.ModTime().Equal(fileInfo.ModTime()) || currentInfo.Size() != fileInfo.Size() {
+		err = fmt.Errorf("%s has changed on disk during the update. Refusing to overwrite it", todo.Filename)
+		return err
+	}
 
-	if err != nil {
+	if err = os.Rename(outputFilename, todo.Filename); err != nil {
 		return err
 	}
 
-	return err
+	syncDir(filepath.Dir(todo.Filename))
+
+	if newInfo, err := os.Stat(todo.Filename); err == nil {
+		if newContent, err := ioutil.ReadFile(todo.Filename); err == nil {
+			todo.snapshot.update(newInfo, newContent)
+		}
+	}
+
+	return nil
 }
 
 // Update updates the file where the Todo is located in-place.
@@ -165,21 +214,28 @@ func (todo Todo) GitCommit(prefix string) error {
 }
 
 // RetrieveStatus retrieves the current status of TODOs issue
-// from GitHub (works for GitLab API too)
-func (todo Todo) RetrieveStatus(creds IssueAPI, repo string) (string, error) {
-	json, err := creds.getIssue(repo, todo)
+// from GitHub (works for GitLab API too). The cache may be nil.
+func (todo Todo) RetrieveStatus(ctx context.Context, creds IssueAPI, repo string, cache *IssueCache) (string, error) {
+	issue, err := cache.getIssue(ctx, creds, repo, todo)
 
 	if err != nil {
 		return "", err
 	}
 
-	return json["state"].(string), nil
+	return issue.State, nil
 }
 
 // Report reports the todo as an Issue, updates the file
 // where the todo is located and commits the changes to the git repo.
-func (todo Todo) Report(creds IssueAPI, repo string, body string) (Todo, error) {
-	return creds.postIssue(repo, todo, body)
+func (todo Todo) Report(ctx context.Context, creds IssueAPI, repo string, body string) (Todo, error) {
+	issue, err := creds.postIssue(ctx, repo, todo, body)
+	if err != nil {
+		return todo, err
+	}
+
+	todo.ID = &issue.ID
+
+	return todo, nil
 }
 
 // IsBodySeperator checks wether the given line contains the
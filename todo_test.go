@@ -3,6 +3,9 @@ package main
 import (
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -100,6 +103,119 @@ func main() {
 	}
 }
 
+func TestTodo_UpdateShouldRefuseChangedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(filename, []byte("// TODO: Rewrite this in rust\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	project := Project{
+		Title:    &TitleConfig{},
+		Keywords: []string{"TODO"},
+	}
+
+	todos := []Todo{}
+	err = project.WalkTodosOfFile(filename, func(todo Todo) error {
+		todos = append(todos, todo)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(todos) != 1 {
+		t.Fatalf("got %d todos, want 1", len(todos))
+	}
+
+	changedContent := "// TODO: Rewrite this in zig\n"
+	if err := ioutil.WriteFile(filename, []byte(changedContent), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	id := "#42"
+	todos[0].ID = &id
+	if err := todos[0].Update(); err == nil {
+		t.Errorf("expected Update to fail on a file changed on disk")
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if got := string(b); got != changedContent {
+		t.Errorf("got:\n%s\nwant:\n%s", got, changedContent)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("temporary files were left behind: %d files in %s", len(files), dir)
+	}
+}
+
+func TestProject_WalkTodosOfFileLongLines(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	longLine := "// " + strings.Repeat("TODO: not a todo ", 10000)
+	fileContent := longLine + "\n\n// TODO: Rewrite this in rust\n"
+	if _, err := tmp.WriteString(fileContent); err != nil {
+		log.Fatal(err)
+	}
+	tmp.Close()
+
+	project := Project{
+		Title:    &TitleConfig{},
+		Keywords: []string{"TODO"},
+	}
+
+	todos := []Todo{}
+	err = project.WalkTodosOfFile(tmp.Name(), func(todo Todo) error {
+		todos = append(todos, todo)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(todos) != 2 {
+		t.Fatalf("got %d todos, want 2", len(todos))
+	}
+
+	if todos[1].Line != 3 {
+		t.Errorf("got line %d, want 3", todos[1].Line)
+	}
+
+	id := "#42"
+	todos[1].ID = &id
+	if err := todos[1].Update(); err != nil {
+		log.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wantFileContent := longLine + "\n\n// TODO(#42): Rewrite this in rust\n"
+	if got := string(b); got != wantFileContent {
+		t.Errorf("long line was not preserved by the update")
+	}
+}
+
 func stringPtrEqual(s1, s2 *string) bool {
 	return derefString(s1) == derefString(s2)
 }